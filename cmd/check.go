@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/lmorchard/linkding-to-opml/internal/config"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the Linkding URL and token",
+	Long: `Check connects to Linkding with the configured URL and token and reports
+whether the API answered. On failure it names the likely cause: a rejected
+token, a URL that isn't a Linkding instance, or a network problem.
+
+Examples:
+  # Check the settings in ./linkding-to-opml.yaml
+  linkding-to-opml check
+
+  # Check a specific configuration file
+  linkding-to-opml check --config /path/to/config.yaml`,
+	Args: cobra.NoArgs,
+	RunE: runCheck,
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
+
+func runCheck(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig(viper.GetString("config"))
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	closeLog := cfg.SetupLogging()
+	defer closeLog()
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("configuration validation failed: %w", err)
+	}
+
+	tlsConfig, err := cfg.TLSConfig()
+	if err != nil {
+		return fmt.Errorf("failed to configure TLS: %w", err)
+	}
+
+	linkdingClient, err := newLinkdingClient(cfg, tlsConfig)
+	if err != nil {
+		return err
+	}
+	if err := linkdingClient.Ping(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Connected to Linkding at %s; the API token was accepted.\n", cfg.Linkding.URL)
+	return nil
+}
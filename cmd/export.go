@@ -1,13 +1,27 @@
 package cmd
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
-	"linkding-to-opml/internal/cache"
-	"linkding-to-opml/internal/config"
-	"linkding-to-opml/internal/feeds"
-	"linkding-to-opml/internal/linkding"
-	"linkding-to-opml/internal/opml"
+	"github.com/lmorchard/linkding-to-opml/internal/cache"
+	"github.com/lmorchard/linkding-to-opml/internal/config"
+	"github.com/lmorchard/linkding-to-opml/internal/feeds"
+	"github.com/lmorchard/linkding-to-opml/internal/linkding"
+	"github.com/lmorchard/linkding-to-opml/internal/metrics"
+	"github.com/lmorchard/linkding-to-opml/internal/notify"
+	"github.com/lmorchard/linkding-to-opml/internal/opml"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -36,7 +50,10 @@ Examples:
   linkding-to-opml export --output my-feeds.opml
 
   # Use custom configuration file
-  linkding-to-opml export --config /path/to/config.yaml`,
+  linkding-to-opml export --config /path/to/config.yaml
+
+  # Write OPML to stdout for use in a pipeline (summary goes to stderr)
+  linkding-to-opml export --output - | gzip > feeds.opml.gz`,
 	RunE: runExport,
 }
 
@@ -45,27 +62,109 @@ func init() {
 	rootCmd.AddCommand(exportCmd)
 
 	// Export-specific flags
-	exportCmd.Flags().StringSlice("tags", []string{}, "Comma-separated list of tags to filter bookmarks (empty = all bookmarks)")
-	exportCmd.Flags().StringP("output", "o", "", "OPML output file path (default: feeds.opml)")
+	exportCmd.Flags().StringSlice("tags", []string{}, "Comma-separated list of tags to filter bookmarks; * is a wildcard, e.g. news/* (empty = all bookmarks)")
+	exportCmd.Flags().String("since", "", "Only export bookmarks added or modified within a duration (e.g. 72h, 7d) or since an RFC3339 timestamp")
+	exportCmd.Flags().StringP("output", "o", "", "OPML output file path, or - for stdout (default: feeds.opml)")
+	exportCmd.Flags().String("sort", "", "Order OPML outlines by feed title or feed URL: title, url, none (default: none)")
+	exportCmd.Flags().String("opml-version", "", "OPML version to write: 1.0 for older readers, or 2.0 (default: 2.0)")
+	exportCmd.Flags().Bool("deterministic", false, "Omit the head timestamps and sort outlines by feed URL, so unchanged feeds produce byte-identical OPML")
+	exportCmd.Flags().String("opml-text-source", "", "What to put in each outline's text attribute, independently of title: title, domain, url (default: title)")
+	exportCmd.Flags().Bool("include-unreachable", false, "Record bookmarks whose feed discovery failed as outlines with type=\"unreachable\"")
+	exportCmd.Flags().Bool("include-icons", false, "Add each feed's icon or logo URL as a non-standard iconUrl outline attribute")
+	exportCmd.Flags().Bool("include-categories", false, "Add the tags of each feed's bookmark as a comma-separated category outline attribute")
+	exportCmd.Flags().Int("max-feeds-per-domain", 0, "Keep at most N feeds per website domain, preferring each site's main feed (0 = unlimited)")
+	exportCmd.Flags().Bool("dedupe-against-existing", false, "Leave out discovered feeds whose feed URL is already saved as a Linkding bookmark")
+	exportCmd.Flags().String("dedupe-against-opml", "", "Leave out discovered feeds whose feed URL is already in this OPML file")
+	exportCmd.Flags().Bool("append", false, "Append discovered feeds to the existing output file, keeping its outlines as-is (no deduplication)")
+	exportCmd.Flags().Bool("merge", false, "Merge discovered feeds into the existing output file, adding only feeds whose xmlUrl isn't already in it")
+	exportCmd.Flags().Bool("backup", false, "Rename an existing output file to <name>.<timestamp>.opml.bak before overwriting it")
+	exportCmd.Flags().Bool("stream", false, "Write each feed's outline as soon as it is discovered instead of building the whole document in memory")
+	exportCmd.Flags().Int("min-feeds", 0, "Abort without writing if the new OPML would contain fewer than N feeds (0 = disabled)")
+	exportCmd.Flags().Int("max-shrink-percent", 0, "Abort without writing if the feed count would drop by more than this percent versus the existing file (0 = disabled)")
 	exportCmd.Flags().String("cache", "", "Cache file path (default: ./linkding-to-opml.gob)")
 	exportCmd.Flags().Int("max-age", 0, "Cache max-age in hours (default: 720)")
-	exportCmd.Flags().String("linkding-token", "", "Linkding API token (required)")
+	exportCmd.Flags().Int("auth-max-age", 0, "Cache max-age in hours for pages that answered 401/403 (default: 24)")
+	exportCmd.Flags().String("skip-list", "", "File of bookmark URLs (one per line) that are never probed for feeds")
+	exportCmd.Flags().Bool("add-skip-on-fail", false, "Append bookmarks whose pages have no feed links to the --skip-list file")
+	exportCmd.Flags().Bool("no-cache", false, "Ignore cached results and force fresh feed discovery (cache is still updated)")
+	exportCmd.Flags().Bool("verify", false, "Re-fetch the feed of each cached result and rediscover the page's feed if it no longer works")
+	exportCmd.Flags().String("linkding-token", "", "Linkding API token (required; prefer --linkding-token-file or LINKDING_TO_OPML_LINKDING_TOKEN to keep it out of shell history)")
+	exportCmd.Flags().String("linkding-token-file", "", "File containing the Linkding API token, instead of an inline token")
 	exportCmd.Flags().String("linkding-url", "", "Linkding server URL (required)")
 	exportCmd.Flags().String("linkding-timeout", "", "Linkding API timeout (default: 30s)")
 	exportCmd.Flags().IntP("concurrency", "c", 0, "Number of concurrent workers (default: 16)")
+	exportCmd.Flags().Int("concurrency-per-host", 0, "Maximum simultaneous requests to any one host, 0 = unlimited (default: 4)")
+	exportCmd.Flags().Duration("deadline", 0, "Stop discovery after this long (e.g. 10m) and write the partial OPML (0 = no deadline)")
+	exportCmd.Flags().Bool("adaptive-concurrency", false, "Start with low concurrency and tune it up to --concurrency based on latency and transient errors")
+	exportCmd.Flags().String("notify-webhook", "", "POST a JSON summary of the run to this URL when the export completes")
+	exportCmd.Flags().String("notify-on", "", "When to send the webhook notification: always, failure (default: always)")
+	exportCmd.Flags().String("metrics-file", "", "Write Prometheus text-format metrics for the run to this file (e.g. for a node_exporter textfile collector)")
+	exportCmd.Flags().String("export-failures", "", "Write the URL and error of each bookmark without a feed to this file (CSV if it ends in .csv, else tab-separated text)")
+	exportCmd.Flags().Bool("domain-stats", false, "Print feed discovery successes and failures per website domain, most failures first")
+	exportCmd.Flags().String("domain-stats-file", "", "Write feed discovery successes and failures per website domain to this file (CSV if it ends in .csv, else a table)")
+	exportCmd.Flags().Bool("validate-feeds", false, "Check newly discovered feeds for common problems (missing link, no items, bad dates, relative URLs, missing GUIDs) and report them")
+	exportCmd.Flags().Bool("fail-fast", false, "Abort on the first error that would fail every bookmark (proxy, network or DNS resolver failure) instead of trying them all")
 	exportCmd.Flags().Bool("save-failed-html", false, "Save HTML content of failed feed discoveries for debugging")
+	exportCmd.Flags().Bool("user-agent-rotate", false, "Rotate through the http.user_agents list, using the next user agent for each bookmark")
+	exportCmd.Flags().Bool("no-common-paths", false, "Never probe common feed locations like /feed or /rss.xml on pages without feed links")
+	exportCmd.Flags().StringSlice("feed-title-fallback", []string{}, "Where to take a title for feeds that have none, tried in order: bookmark, page, domain (default: none, untitled feeds are skipped)")
+	exportCmd.Flags().Bool("retry-blocked", false, "Retry pages that look like a 403/404 error page served with a 200 once with a browser user agent")
+	exportCmd.Flags().Bool("insecure-skip-verify", false, "DANGEROUS: disable TLS certificate verification for all requests (exposes traffic to interception)")
+	exportCmd.Flags().String("ca-cert-file", "", "PEM file with additional root CA certificates to trust (e.g. a private CA)")
+	exportCmd.Flags().String("proxy", "", "Proxy URL for all requests, e.g. http://proxy:3128 or socks5://127.0.0.1:1080 (default: HTTP_PROXY/HTTPS_PROXY env)")
 	exportCmd.Flags().String("debug-output-dir", "", "Directory to save debug output (default: ./debug)")
 
 	// Bind flags to viper
 	_ = viper.BindPFlag("tags", exportCmd.Flags().Lookup("tags"))
+	_ = viper.BindPFlag("since", exportCmd.Flags().Lookup("since"))
 	_ = viper.BindPFlag("output", exportCmd.Flags().Lookup("output"))
+	_ = viper.BindPFlag("append", exportCmd.Flags().Lookup("append"))
+	_ = viper.BindPFlag("merge", exportCmd.Flags().Lookup("merge"))
+	_ = viper.BindPFlag("sort", exportCmd.Flags().Lookup("sort"))
+	_ = viper.BindPFlag("opml_version", exportCmd.Flags().Lookup("opml-version"))
+	_ = viper.BindPFlag("opml_text_source", exportCmd.Flags().Lookup("opml-text-source"))
+	_ = viper.BindPFlag("deterministic", exportCmd.Flags().Lookup("deterministic"))
+	_ = viper.BindPFlag("include_unreachable", exportCmd.Flags().Lookup("include-unreachable"))
+	_ = viper.BindPFlag("include_icons", exportCmd.Flags().Lookup("include-icons"))
+	_ = viper.BindPFlag("include_categories", exportCmd.Flags().Lookup("include-categories"))
+	_ = viper.BindPFlag("max_feeds_per_domain", exportCmd.Flags().Lookup("max-feeds-per-domain"))
+	_ = viper.BindPFlag("dedupe_against_existing", exportCmd.Flags().Lookup("dedupe-against-existing"))
+	_ = viper.BindPFlag("dedupe_against_opml", exportCmd.Flags().Lookup("dedupe-against-opml"))
+	_ = viper.BindPFlag("backup", exportCmd.Flags().Lookup("backup"))
+	_ = viper.BindPFlag("stream", exportCmd.Flags().Lookup("stream"))
+	_ = viper.BindPFlag("min_feeds", exportCmd.Flags().Lookup("min-feeds"))
+	_ = viper.BindPFlag("max_shrink_percent", exportCmd.Flags().Lookup("max-shrink-percent"))
 	_ = viper.BindPFlag("cache.file_path", exportCmd.Flags().Lookup("cache"))
 	_ = viper.BindPFlag("cache.max_age", exportCmd.Flags().Lookup("max-age"))
+	_ = viper.BindPFlag("cache.auth_required_max_age", exportCmd.Flags().Lookup("auth-max-age"))
+	_ = viper.BindPFlag("skip_list.file_path", exportCmd.Flags().Lookup("skip-list"))
+	_ = viper.BindPFlag("skip_list.add_on_fail", exportCmd.Flags().Lookup("add-skip-on-fail"))
+	_ = viper.BindPFlag("cache.disabled", exportCmd.Flags().Lookup("no-cache"))
+	_ = viper.BindPFlag("cache.verify", exportCmd.Flags().Lookup("verify"))
+	_ = viper.BindPFlag("discovery.common_paths_disabled", exportCmd.Flags().Lookup("no-common-paths"))
+	_ = viper.BindPFlag("discovery.title_fallback", exportCmd.Flags().Lookup("feed-title-fallback"))
 	_ = viper.BindPFlag("linkding.token", exportCmd.Flags().Lookup("linkding-token"))
+	_ = viper.BindPFlag("linkding.token_file", exportCmd.Flags().Lookup("linkding-token-file"))
 	_ = viper.BindPFlag("linkding.url", exportCmd.Flags().Lookup("linkding-url"))
 	_ = viper.BindPFlag("linkding.timeout", exportCmd.Flags().Lookup("linkding-timeout"))
 	_ = viper.BindPFlag("concurrency", exportCmd.Flags().Lookup("concurrency"))
+	_ = viper.BindPFlag("http.max_per_host", exportCmd.Flags().Lookup("concurrency-per-host"))
+	_ = viper.BindPFlag("deadline", exportCmd.Flags().Lookup("deadline"))
+	_ = viper.BindPFlag("adaptive_concurrency", exportCmd.Flags().Lookup("adaptive-concurrency"))
+	_ = viper.BindPFlag("notify.webhook", exportCmd.Flags().Lookup("notify-webhook"))
+	_ = viper.BindPFlag("notify.on", exportCmd.Flags().Lookup("notify-on"))
+	_ = viper.BindPFlag("metrics_file", exportCmd.Flags().Lookup("metrics-file"))
+	_ = viper.BindPFlag("export_failures", exportCmd.Flags().Lookup("export-failures"))
+	_ = viper.BindPFlag("domain_stats", exportCmd.Flags().Lookup("domain-stats"))
+	_ = viper.BindPFlag("domain_stats_file", exportCmd.Flags().Lookup("domain-stats-file"))
+	_ = viper.BindPFlag("validate_feeds", exportCmd.Flags().Lookup("validate-feeds"))
+	_ = viper.BindPFlag("fail_fast", exportCmd.Flags().Lookup("fail-fast"))
 	_ = viper.BindPFlag("save_failed_html", exportCmd.Flags().Lookup("save-failed-html"))
+	_ = viper.BindPFlag("http.user_agent_rotate", exportCmd.Flags().Lookup("user-agent-rotate"))
+	_ = viper.BindPFlag("http.retry_blocked", exportCmd.Flags().Lookup("retry-blocked"))
+	_ = viper.BindPFlag("http.insecure_skip_verify", exportCmd.Flags().Lookup("insecure-skip-verify"))
+	_ = viper.BindPFlag("http.ca_cert_file", exportCmd.Flags().Lookup("ca-cert-file"))
+	_ = viper.BindPFlag("http.proxy", exportCmd.Flags().Lookup("proxy"))
 	_ = viper.BindPFlag("debug_output_dir", exportCmd.Flags().Lookup("debug-output-dir"))
 }
 
@@ -78,93 +177,511 @@ func runExport(cmd *cobra.Command, args []string) error {
 	}
 
 	// Set up logging
-	cfg.SetupLogging()
+	closeLog := cfg.SetupLogging()
+	defer closeLog()
 
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
 		return fmt.Errorf("configuration validation failed: %w", err)
 	}
 
+	// User-facing messages go to stderr when the OPML itself is written to stdout
+	var out io.Writer = os.Stdout
+	if cfg.WritesToStdout() {
+		out = os.Stderr
+	}
+
+	// Stop gracefully on Ctrl-C/SIGTERM or when the --deadline passes: abandon
+	// in-flight requests, then save the cache and partial OPML. A second
+	// signal terminates immediately.
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if cfg.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Deadline)
+		defer cancel()
+	}
+
+	startTime := time.Now()
+	stats, err := Export(ctx, cfg, out)
+	if cfg.MetricsFile != "" {
+		if metricsErr := metrics.WriteTextFile(cfg.MetricsFile, stats, err != nil, time.Now()); metricsErr != nil {
+			logrus.WithError(metricsErr).Warn("Failed to write metrics file")
+		}
+	}
+	if cfg.Notify.Webhook != "" {
+		sendNotification(cfg, stats, err, startTime)
+	}
+	return err
+}
+
+// sendNotification posts the run summary to the configured webhook. Delivery
+// problems are logged but never change the outcome of the export.
+func sendNotification(cfg *config.Config, stats *feeds.ProcessingStats, exportErr error, startTime time.Time) {
+	endTime := time.Now()
+	summary := &notify.Summary{
+		Status:          "success",
+		Output:          cfg.Output,
+		DurationSeconds: endTime.Sub(startTime).Seconds(),
+		StartTime:       startTime.Format(time.RFC3339),
+		EndTime:         endTime.Format(time.RFC3339),
+	}
+	if exportErr != nil {
+		summary.Status = "failure"
+		summary.Error = exportErr.Error()
+	}
+	if stats != nil {
+		summary.TotalBookmarks = stats.TotalBookmarks
+		summary.SuccessfulFeeds = stats.SuccessfulFeeds
+		summary.FailedDiscoveries = stats.FailedDiscoveries
+		summary.CacheHits = stats.CacheHits
+		summary.NewDiscoveries = stats.NewDiscoveries
+		summary.Interrupted = stats.Interrupted
+	}
+
+	if !notify.ShouldSend(cfg.Notify.On, summary) {
+		logrus.Debug("Skipping notification for successful run")
+		return
+	}
+
+	// The export context may already be cancelled by an interrupt or deadline
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.Timeout)
+	defer cancel()
+	if err := notify.SendWebhook(ctx, cfg.Notify.Webhook, summary, cfg.HTTP.Timeout); err != nil {
+		logrus.WithError(err).Warn("Failed to send completion notification")
+	}
+}
+
+// exportTitle is the title of generated OPML documents
+const exportTitle = "Feeds exported from Linkding"
+
+// Export runs the export pipeline for an already loaded and validated
+// configuration, writing user-facing messages to out. It returns the
+// processing statistics, or nil stats if there were no bookmarks to process.
+// Cancelling ctx stops feed discovery early; whatever was discovered is still
+// cached and written, and an error is returned to mark the export incomplete.
+func Export(ctx context.Context, cfg *config.Config, out io.Writer) (*feeds.ProcessingStats, error) {
 	logrus.Info("Starting linkding-to-opml export process")
 
-	// Step 1: Initialize cache
+	// Step 1: Initialize cache and skip list
+	var skipList *cache.SkipList
+	if cfg.SkipList.FilePath != "" {
+		var err error
+		skipList, err = cache.LoadSkipList(cfg.SkipList.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load skip list: %w", err)
+		}
+	}
+
 	logrus.Debug("Initializing cache")
-	cache := cache.NewCache(cfg.Cache.FilePath)
+	cache, err := cache.New(cfg.Cache.Backend, cfg.Cache.FilePath, cfg.Cache.Format, cfg.Cache.Compress)
+	if err != nil {
+		return nil, err
+	}
 	if err := cache.LoadCache(); err != nil {
-		return fmt.Errorf("failed to load cache: %w", err)
+		return nil, fmt.Errorf("failed to load cache: %w", err)
+	}
+	defer cache.Close()
+
+	tlsConfig, err := cfg.TLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure TLS: %w", err)
 	}
 
 	// Step 2: Create Linkding API client
-	logrus.Debug("Creating Linkding API client")
-	linkdingClient, err := linkding.NewClient(cfg.Linkding.Token, cfg.Linkding.URL, cfg.Linkding.Timeout)
+	linkdingClient, err := newLinkdingClient(cfg, tlsConfig)
 	if err != nil {
-		return fmt.Errorf("failed to create Linkding client: %w", err)
+		return nil, err
+	}
+	if err := linkdingClient.Ping(); err != nil {
+		return nil, fmt.Errorf("linkding preflight check failed: %w", err)
 	}
 
 	// Step 3: Fetch bookmarks from Linkding
 	logrus.Info("Fetching bookmarks from Linkding API")
 	bookmarks, err := linkdingClient.FetchBookmarks(cfg.Tags)
 	if err != nil {
-		return fmt.Errorf("failed to fetch bookmarks: %w", err)
+		return nil, fmt.Errorf("failed to fetch bookmarks: %w", err)
+	}
+
+	// Without a tag filter this is every bookmark, which saves fetching them
+	// again to find feeds that are already bookmarked
+	var allBookmarks []*linkding.Bookmark
+	if len(cfg.Tags) == 0 {
+		allBookmarks = bookmarks
+	}
+
+	// Narrow to recently added or modified bookmarks when --since is set
+	cutoff, err := cfg.SinceCutoff(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !cutoff.IsZero() {
+		bookmarks = linkding.FilterSince(bookmarks, cutoff)
 	}
 
 	if len(bookmarks) == 0 {
 		logrus.Warn("No bookmarks found matching the specified criteria")
 		if !cfg.Quiet {
-			fmt.Println("No bookmarks found. Nothing to export.")
+			fmt.Fprintln(out, "No bookmarks found. Nothing to export.")
 		}
-		return nil
+		return nil, nil
 	}
 
 	// Step 4: Process bookmarks with concurrent feed discovery
 	logrus.WithField("bookmark_count", len(bookmarks)).Info("Starting feed discovery")
 
-	processingConfig := feeds.ProcessingConfig{
-		Concurrency: cfg.Concurrency,
-		MaxAge:      cfg.Cache.MaxAge,
-		UserAgent:   cfg.HTTP.UserAgent,
-		HTTPConfig: feeds.HTTPConfig{
-			Timeout:      cfg.HTTP.Timeout,
-			UserAgent:    cfg.HTTP.UserAgent,
-			MaxRedirects: cfg.HTTP.MaxRedirects,
-		},
-		Verbose:        cfg.Verbose,
-		SaveFailedHTML: cfg.SaveFailedHTML,
-		DebugOutputDir: cfg.DebugOutputDir,
+	processingConfig := cfg.ProcessingConfig(tlsConfig)
+	processingConfig.SkipList = skipList
+
+	// With --stream, each feed's outline is written as soon as it is discovered
+	var stream *opml.StreamWriter
+	var streamErr error
+	if cfg.Stream {
+		stream, err = opml.CreateStreamFile(cfg.Output, exportTitle, opml.StreamOptions{
+			IncludeIcons: cfg.IncludeIcons,
+			TextSource:   cfg.OPMLTextSource,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to write OPML file: %w", err)
+		}
+		defer stream.Abort()
+
+		processingConfig.OnFeed = func(result *feeds.FeedDiscoveryResult) {
+			if streamErr == nil {
+				streamErr = stream.WriteResult(result)
+			}
+		}
+	}
+
+	results, failed, stats := feeds.ProcessBookmarks(ctx, bookmarks, cache, processingConfig)
+	if stats.FatalError != nil {
+		return stats, fmt.Errorf("stopped on the first hard error (--fail-fast): %w", stats.FatalError)
 	}
 
-	results, stats := feeds.ProcessBookmarks(bookmarks, cache, processingConfig)
+	if cfg.ExportFailures != "" {
+		if err := feeds.WriteFailuresFile(cfg.ExportFailures, failed); err != nil {
+			return stats, err
+		}
+	}
+	if cfg.DomainStatsFile != "" {
+		if err := feeds.WriteDomainStatsFile(cfg.DomainStatsFile, stats); err != nil {
+			return stats, err
+		}
+	}
 
-	if len(results) == 0 {
+	// Leave out feeds that are already bookmarked or in an existing OPML
+	if cfg.DedupeAgainstExisting || cfg.DedupeAgainstOPML != "" {
+		known, err := loadKnownFeeds(cfg, linkdingClient, allBookmarks)
+		if err != nil {
+			return stats, err
+		}
+		results, stats.KnownFeeds = feeds.DropKnownFeeds(results, known)
+	}
+
+	if len(results) == 0 && !(cfg.IncludeUnreachable && len(failed) > 0) {
+		if stats.KnownFeeds > 0 {
+			logrus.Warn("All discovered feeds are already known")
+			if !cfg.Quiet {
+				fmt.Fprintln(out, "Every discovered feed is already bookmarked or in the existing OPML. No OPML file will be created.")
+			}
+			return stats, interruptedError(ctx, stats)
+		}
 		logrus.Warn("No feeds discovered from bookmarks")
 		if !cfg.Quiet {
-			fmt.Println("No feeds were discovered from the bookmarks. No OPML file will be created.")
+			fmt.Fprintln(out, "No feeds were discovered from the bookmarks. No OPML file will be created.")
 		}
-		return nil
+		return stats, interruptedError(ctx, stats)
+	}
+
+	if stream != nil {
+		if streamErr != nil {
+			return stats, fmt.Errorf("failed to write OPML file: %w", streamErr)
+		}
+		backupPath, err := stream.Commit(cfg.Backup)
+		if err != nil {
+			return stats, fmt.Errorf("failed to write OPML file: %w", err)
+		}
+		return finishExport(ctx, cfg, out, stats, results, nil, backupPath)
 	}
 
 	// Step 5: Generate OPML
 	logrus.WithField("feed_count", len(results)).Info("Generating OPML document")
-	opmlDoc := opml.GenerateOPML(results, "Feeds exported from Linkding")
+	opmlDoc := opml.GenerateOPML(results, exportTitle)
+	if cfg.IncludeIcons {
+		opml.AddFeedIcons(opmlDoc, results)
+	}
+	if cfg.IncludeCategories {
+		opml.AddFeedCategories(opmlDoc, results)
+	}
+	if cfg.IncludeUnreachable {
+		opml.AddUnreachableOutlines(opmlDoc, failed)
+	}
+	var droppedFeeds map[string]int
+	if cfg.MaxFeedsPerDomain > 0 {
+		droppedFeeds = opml.LimitFeedsPerDomain(opmlDoc, cfg.MaxFeedsPerDomain)
+	}
+
+	if err := opmlDoc.SetOutlineText(cfg.OPMLTextSource); err != nil {
+		return stats, err
+	}
+
+	// Sort only the newly generated outlines so appended-to files keep their order
+	sortMode := cfg.Sort
+	if cfg.Deterministic {
+		sortMode = opml.SortURL
+	}
+	if err := opmlDoc.SortOutlines(sortMode); err != nil {
+		return stats, err
+	}
+
+	if cfg.Append || cfg.Merge {
+		opmlDoc, err = appendToExistingOPML(opmlDoc, cfg.Output, cfg.Merge)
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	// Drop the timestamps that would otherwise change on every run, including
+	// those of an appended-to file
+	if cfg.Deterministic {
+		opmlDoc.ClearTimestamps()
+	}
+
+	// Convert the whole document, including appended outlines, to the requested version
+	if err := opmlDoc.SetVersion(cfg.OPMLVersion); err != nil {
+		return stats, err
+	}
 
 	// Step 6: Validate OPML
 	if err := opml.ValidateOPML(opmlDoc); err != nil {
-		return fmt.Errorf("generated OPML is invalid: %w", err)
+		return stats, fmt.Errorf("generated OPML is invalid: %w", err)
+	}
+
+	// Step 6.5: Refuse to replace a good export with a suspiciously small one
+	if err := checkFeedCountGuard(cfg, opmlDoc); err != nil {
+		return stats, err
 	}
 
 	// Step 7: Write OPML file
 	logrus.WithField("output_file", cfg.Output).Info("Writing OPML file")
-	if err := opml.WriteOPML(opmlDoc, cfg.Output); err != nil {
-		return fmt.Errorf("failed to write OPML file: %w", err)
+	var backupPath string
+	if cfg.Backup {
+		backupPath, err = opml.WriteOPMLWithBackup(opmlDoc, cfg.Output)
+	} else {
+		err = opml.WriteOPML(opmlDoc, cfg.Output)
+	}
+	if err != nil {
+		return stats, fmt.Errorf("failed to write OPML file: %w", err)
 	}
 
+	return finishExport(ctx, cfg, out, stats, results, droppedFeeds, backupPath)
+}
+
+// finishExport displays the summary of a written export and reports whether
+// it ran to completion
+func finishExport(ctx context.Context, cfg *config.Config, out io.Writer, stats *feeds.ProcessingStats, results []*feeds.FeedDiscoveryResult, droppedFeeds map[string]int, backupPath string) (*feeds.ProcessingStats, error) {
 	// Step 8: Display summary statistics
 	if !cfg.Quiet {
 		summary := stats.FormatProcessingSummary(false)
-		fmt.Println(summary)
-		fmt.Printf("OPML file written to: %s\n", cfg.Output)
+		fmt.Fprintln(out, summary)
+		if report := formatFeedWarnings(results); report != "" {
+			fmt.Fprintln(out, report)
+		}
+		if len(droppedFeeds) > 0 {
+			fmt.Fprintln(out, formatDroppedFeeds(droppedFeeds, cfg.MaxFeedsPerDomain))
+		}
+		if cfg.DomainStats {
+			fmt.Fprintln(out, stats.FormatDomainStats())
+		}
+		if cfg.WritesToStdout() {
+			fmt.Fprintln(out, "OPML written to stdout")
+		} else {
+			fmt.Fprintf(out, "OPML file written to: %s\n", cfg.Output)
+		}
+		if backupPath != "" {
+			fmt.Fprintf(out, "Previous OPML backed up to: %s\n", backupPath)
+		}
+	}
+
+	if stats.Interrupted {
+		return stats, interruptedError(ctx, stats)
 	}
 
 	logrus.Info("Export process completed successfully")
+	return stats, nil
+}
+
+// interruptedError reports an export that was cancelled or hit its deadline
+// before all bookmarks were processed, or returns nil if processing ran to completion
+func interruptedError(ctx context.Context, stats *feeds.ProcessingStats) error {
+	if !stats.Interrupted {
+		return nil
+	}
+
+	reason := "interrupted"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		reason = "reached its deadline"
+	}
+	return fmt.Errorf("export %s after %d of %d bookmarks; partial results were saved", reason, stats.Processed, stats.TotalBookmarks-stats.DuplicateURLs)
+}
+
+// loadKnownFeeds collects the feed URLs to leave out of the export: the URLs
+// of all Linkding bookmarks with --dedupe-against-existing, fetched in one
+// batch unless allBookmarks already holds them, and the xmlUrls of the
+// --dedupe-against-opml file
+func loadKnownFeeds(cfg *config.Config, client *linkding.Client, allBookmarks []*linkding.Bookmark) (feeds.KnownFeeds, error) {
+	known := make(feeds.KnownFeeds)
+
+	if cfg.DedupeAgainstExisting {
+		if allBookmarks == nil {
+			var err error
+			allBookmarks, err = client.FetchBookmarks(nil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch bookmarks to check for existing feeds: %w", err)
+			}
+		}
+		for _, bookmark := range allBookmarks {
+			known.Add(bookmark.URL)
+		}
+	}
+
+	if cfg.DedupeAgainstOPML != "" {
+		existing, err := opml.ReadOPML(cfg.DedupeAgainstOPML)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OPML to check for existing feeds: %w", err)
+		}
+		for _, feedURL := range existing.FeedURLs() {
+			known.Add(feedURL)
+		}
+	}
+
+	logrus.WithField("known_feeds", len(known)).Debug("Loaded existing feed URLs to leave out")
+	return known, nil
+}
+
+// newLinkdingClient creates the Linkding API client, routing its requests
+// through the configured TLS and proxy settings
+func newLinkdingClient(cfg *config.Config, tlsConfig *tls.Config) (*linkding.Client, error) {
+	logrus.Debug("Creating Linkding API client")
+	err := linkding.ConfigureDefaultTransport(func(transport *http.Transport) error {
+		if tlsConfig != nil {
+			transport.TLSClientConfig = tlsConfig
+		}
+		return feeds.ApplyProxy(transport, cfg.HTTP.Proxy)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure Linkding HTTP transport: %w", err)
+	}
+
+	client, err := linkding.NewClient(cfg.Linkding.Token, cfg.Linkding.URL, cfg.Linkding.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Linkding client: %w", err)
+	}
+	return client, nil
+}
+
+// formatDroppedFeeds reports the feeds dropped by --max-feeds-per-domain, by domain
+func formatDroppedFeeds(dropped map[string]int, max int) string {
+	domains := make([]string, 0, len(dropped))
+	total := 0
+	for domain, count := range dropped {
+		domains = append(domains, domain)
+		total += count
+	}
+	sort.Strings(domains)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Dropped %d feeds over the limit of %d per domain:", total, max)
+	for _, domain := range domains {
+		fmt.Fprintf(&b, "\n  %s: %d", domain, dropped[domain])
+	}
+	return b.String()
+}
+
+// formatFeedWarnings lists the validation warnings of each feed that has any
+func formatFeedWarnings(results []*feeds.FeedDiscoveryResult) string {
+	var report strings.Builder
+	for _, result := range results {
+		if len(result.Warnings) == 0 {
+			continue
+		}
+		fmt.Fprintf(&report, "\n%s", result.FeedURL)
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(&report, "\n  - %s", warning)
+		}
+	}
+	if report.Len() == 0 {
+		return ""
+	}
+	return "Feed validation warnings:" + report.String()
+}
+
+// checkFeedCountGuard enforces --min-feeds and --max-shrink-percent against the
+// generated document and the OPML file currently at the output path
+func checkFeedCountGuard(cfg *config.Config, generated *opml.OPML) error {
+	if cfg.MinFeeds <= 0 && cfg.MaxShrinkPercent <= 0 {
+		return nil
+	}
+
+	newCount := generated.FeedCount()
+	if cfg.MinFeeds > 0 && newCount < cfg.MinFeeds {
+		return fmt.Errorf("refusing to write OPML: %d feeds is below --min-feeds %d", newCount, cfg.MinFeeds)
+	}
+
+	if cfg.MaxShrinkPercent <= 0 || cfg.WritesToStdout() {
+		return nil
+	}
+
+	existing, err := opml.ReadOPML(cfg.Output)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read existing OPML for shrink check: %w", err)
+	}
+
+	oldCount := existing.FeedCount()
+	if oldCount == 0 || newCount >= oldCount {
+		return nil
+	}
+
+	shrinkPercent := float64(oldCount-newCount) * 100 / float64(oldCount)
+	logrus.WithFields(logrus.Fields{
+		"existing_feeds": oldCount,
+		"new_feeds":      newCount,
+		"shrink_percent": shrinkPercent,
+	}).Debug("Compared feed count with existing OPML")
+
+	if shrinkPercent > float64(cfg.MaxShrinkPercent) {
+		return fmt.Errorf("refusing to overwrite %s: feed count would drop from %d to %d (%.0f%%, above --max-shrink-percent %d)",
+			cfg.Output, oldCount, newCount, shrinkPercent, cfg.MaxShrinkPercent)
+	}
+
 	return nil
 }
+
+// appendToExistingOPML appends the generated outlines to the OPML file at
+// outputPath, or returns the generated document unchanged if the file doesn't
+// exist yet. With onlyNew, feeds already in the file are not appended again.
+func appendToExistingOPML(generated *opml.OPML, outputPath string, onlyNew bool) (*opml.OPML, error) {
+	existing, err := opml.ReadOPML(outputPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			logrus.WithField("output_file", outputPath).Info("Output file does not exist yet, nothing to append to")
+			return generated, nil
+		}
+		return nil, fmt.Errorf("failed to read existing OPML for append: %w", err)
+	}
+
+	if onlyNew {
+		merged, _ := opml.MergeNewOutlines(existing, generated)
+		return merged, nil
+	}
+	return opml.AppendOPML(existing, generated), nil
+}
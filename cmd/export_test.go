@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lmorchard/linkding-to-opml/internal/config"
+	"github.com/lmorchard/linkding-to-opml/internal/opml"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newTestSite serves a blog page linking to its RSS feed and a page with no
+// feed at all
+func newTestSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/blog", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Blog</title><link rel="alternate" type="application/rss+xml" href="/blog/feed.xml"></head></html>`)
+	})
+	mux.HandleFunc("/blog/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, `<rss version="2.0"><channel><title>Test Blog</title><link>http://example.com/</link><item><title>Post</title></item></channel></rss>`)
+	})
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>No feed here</title></head></html>`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// testLinkding is a fake Linkding server that remembers which bookmarks were deleted
+type testLinkding struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	deleted []int
+}
+
+// Deleted returns the IDs of the bookmarks deleted so far
+func (l *testLinkding) Deleted() []int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]int(nil), l.deleted...)
+}
+
+// newTestLinkding serves the Linkding bookmarks API with a bookmark for each
+// URL, numbered from 1
+func newTestLinkding(t *testing.T, token string, urls ...string) *testLinkding {
+	t.Helper()
+	api := &testLinkding{}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, "/api/bookmarks/") {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			id, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/bookmarks/"), "/"))
+			if err != nil || id < 1 || id > len(urls) {
+				http.NotFound(w, r)
+				return
+			}
+			api.mu.Lock()
+			api.deleted = append(api.deleted, id)
+			api.mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		results := make([]map[string]interface{}, 0, len(urls))
+		for i, url := range urls {
+			results = append(results, map[string]interface{}{
+				"id":            i + 1,
+				"url":           url,
+				"title":         "Bookmark",
+				"tag_names":     []string{},
+				"date_added":    "2024-01-01T00:00:00Z",
+				"date_modified": "2024-01-01T00:00:00Z",
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"count":   len(results),
+			"results": results,
+		})
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+func TestExportWritesDiscoveredFeeds(t *testing.T) {
+	logrus.SetOutput(io.Discard)
+	t.Cleanup(func() { logrus.SetOutput(os.Stderr) })
+
+	site := newTestSite(t)
+	api := newTestLinkding(t, "secret", site.URL+"/blog", site.URL+"/plain")
+
+	cfg, err := config.DefaultConfig()
+	if err != nil {
+		t.Fatalf("DefaultConfig() error = %v", err)
+	}
+	dir := t.TempDir()
+	cfg.Linkding.URL = api.URL
+	cfg.Linkding.Token = "secret"
+	cfg.Cache.FilePath = filepath.Join(dir, "cache.gob")
+	cfg.Output = filepath.Join(dir, "feeds.opml")
+	cfg.Discovery.CommonPathsDisabled = true
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	stats, err := Export(context.Background(), cfg, &out)
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if stats.SuccessfulFeeds != 1 || stats.FailedDiscoveries != 1 {
+		t.Errorf("stats = %d feeds, %d failed; want 1 feed, 1 failed", stats.SuccessfulFeeds, stats.FailedDiscoveries)
+	}
+	if !strings.Contains(out.String(), "OPML file written to: "+cfg.Output) {
+		t.Errorf("summary does not mention the output file:\n%s", out.String())
+	}
+
+	doc, err := opml.ReadOPML(cfg.Output)
+	if err != nil {
+		t.Fatalf("ReadOPML() error = %v", err)
+	}
+	if doc.Head.Title != exportTitle {
+		t.Errorf("head title = %q, want %q", doc.Head.Title, exportTitle)
+	}
+	if len(doc.Body.Outlines) != 1 {
+		t.Fatalf("got %d outlines, want 1: %+v", len(doc.Body.Outlines), doc.Body.Outlines)
+	}
+	outline := doc.Body.Outlines[0]
+	if outline.XMLURL != site.URL+"/blog/feed.xml" {
+		t.Errorf("xmlUrl = %q, want %q", outline.XMLURL, site.URL+"/blog/feed.xml")
+	}
+	if outline.Title != "Test Blog" {
+		t.Errorf("title = %q, want %q", outline.Title, "Test Blog")
+	}
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/lmorchard/linkding-to-opml/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a starter configuration file",
+	Long: `Init writes a commented configuration file filled in with the default
+settings and placeholders for your Linkding token and URL.
+
+The file is written to ./linkding-to-opml.yaml, where export looks for it, unless
+--path names another location. An existing file is never overwritten without
+--force.
+
+Examples:
+  # Create ./linkding-to-opml.yaml, then edit in your token and URL
+  linkding-to-opml init
+
+  # Write the config somewhere else
+  linkding-to-opml init --path ~/.config/linkding-to-opml.yaml`,
+	Args: cobra.NoArgs,
+	RunE: runInit,
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+
+	initCmd.Flags().String("path", "linkding-to-opml.yaml", "Where to write the configuration file")
+	initCmd.Flags().Bool("force", false, "Overwrite the file if it already exists")
+}
+
+func runInit(cmd *cobra.Command, args []string) error {
+	path, _ := cmd.Flags().GetString("path")
+	force, _ := cmd.Flags().GetBool("force")
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(path, flags, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s already exists; use --force to overwrite it", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create config file: %w", err)
+	}
+
+	if err := config.WriteInitialConfig(file); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	fmt.Printf("Wrote %s. Edit it to set your Linkding token and URL, then run: linkding-to-opml export\n", path)
+	return nil
+}
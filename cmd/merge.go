@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lmorchard/linkding-to-opml/internal/config"
+	"github.com/lmorchard/linkding-to-opml/internal/opml"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge OUTPUT INPUT INPUT...",
+	Short: "Merge several OPML files into one",
+	Long: `Merge combines the feeds from two or more OPML files into a single OPML file.
+
+Feeds are deduplicated by their xmlUrl, keeping the title from the first file
+in which each feed appears. Folders are preserved, and folders with the same
+name in different files are merged together.
+
+Use - as the output path to write the merged OPML to stdout, or as one input
+path to read that OPML from stdin.
+
+Examples:
+  # Merge two topic lists into a master list
+  linkding-to-opml merge all.opml tech.opml news.opml
+
+  # Set the title of the merged document
+  linkding-to-opml merge all.opml tech.opml news.opml --title "All my feeds"`,
+	Args: validateMergeArgs,
+	RunE: runMerge,
+}
+
+// validateMergeArgs requires an output and at least two inputs, at most one
+// of which is stdin, since stdin can only be read once
+func validateMergeArgs(cmd *cobra.Command, args []string) error {
+	if err := cobra.MinimumNArgs(3)(cmd, args); err != nil {
+		return err
+	}
+
+	stdinInputs := 0
+	for _, path := range args[1:] {
+		if path == "-" {
+			stdinInputs++
+		}
+	}
+	if stdinInputs > 1 {
+		return fmt.Errorf("stdin (-) can only be used as one input")
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(mergeCmd)
+
+	mergeCmd.Flags().String("title", "", "Title for the merged OPML (default: combined input titles)")
+}
+
+func runMerge(cmd *cobra.Command, args []string) error {
+	outputPath, inputPaths := args[0], args[1:]
+
+	cfg, err := config.LoadConfig(viper.GetString("config"))
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	cfg.Output = outputPath
+
+	closeLog := cfg.SetupLogging()
+	defer closeLog()
+
+	out := os.Stdout
+	if cfg.WritesToStdout() {
+		out = os.Stderr
+	}
+
+	// Read every input before writing anything
+	docs := make([]*opml.OPML, 0, len(inputPaths))
+	titles := make([]string, 0, len(inputPaths))
+	for _, path := range inputPaths {
+		doc, err := opml.ReadOPML(path)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, doc)
+		if doc.Head.Title != "" {
+			titles = append(titles, doc.Head.Title)
+		}
+	}
+
+	title, _ := cmd.Flags().GetString("title")
+	if title == "" {
+		title = "Merged feeds"
+		if len(titles) > 0 {
+			title = strings.Join(titles, " + ")
+		}
+	}
+
+	merged, duplicates := opml.MergeOPML(docs, title)
+
+	if err := opml.ValidateOPML(merged); err != nil {
+		return fmt.Errorf("merged OPML is invalid: %w", err)
+	}
+
+	if err := opml.WriteOPML(merged, outputPath); err != nil {
+		return fmt.Errorf("failed to write merged OPML: %w", err)
+	}
+
+	if !cfg.Quiet {
+		fmt.Fprintf(out, "Merged %d files into %s (%d duplicate feeds removed)\n", len(inputPaths), outputPath, duplicates)
+	}
+
+	logrus.Info("Merge completed successfully")
+	return nil
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import "testing"
+
+func TestValidateMergeArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{"two inputs", []string{"all.opml", "a.opml", "b.opml"}, false},
+		{"one stdin input", []string{"all.opml", "-", "b.opml"}, false},
+		{"stdout output and stdin input", []string{"-", "-", "b.opml"}, false},
+		{"too few inputs", []string{"all.opml", "a.opml"}, true},
+		{"stdin twice", []string{"all.opml", "-", "b.opml", "-"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMergeArgs(mergeCmd, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateMergeArgs(%v) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+		})
+	}
+}
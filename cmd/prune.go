@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/lmorchard/linkding-to-opml/internal/cache"
+	"github.com/lmorchard/linkding-to-opml/internal/config"
+	"github.com/lmorchard/linkding-to-opml/internal/feeds"
+	"github.com/lmorchard/linkding-to-opml/internal/linkding"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune --prune-dead",
+	Short: "Delete Linkding bookmarks whose pages are gone",
+	Long: `Prune re-checks every bookmark and finds the ones whose pages no longer exist:
+the page answers 404 Not Found or 410 Gone, or its host name doesn't resolve.
+
+Each check is recorded in the cache. A bookmark only becomes a candidate once
+its page has been gone on --min-failures checks in a row and for at least
+--min-gone-age, so a site that is briefly down is never deleted. Checks less
+than the cache max age apart count as one, however often prune runs. Any other
+result, including a timeout or a page without a feed, resets the count.
+
+Without --confirm, prune only lists the bookmarks it would delete. With
+--confirm, it deletes them from Linkding. Deletion cannot be undone, so
+--confirm is refused when more than --max-gone-percent of the checked pages
+are gone on this run, which points at a DNS or network problem rather than
+dead sites.
+
+Examples:
+  # List bookmarks whose pages have been gone on 3 checks in a row
+  linkding-to-opml prune --prune-dead
+
+  # Delete them
+  linkding-to-opml prune --prune-dead --confirm
+
+  # Only consider bookmarks tagged "news", and require 5 checks in a row
+  linkding-to-opml prune --prune-dead --tags news --min-failures 5`,
+	Args: cobra.NoArgs,
+	RunE: runPrune,
+}
+
+// PruneOptions controls which bookmarks Prune deletes
+type PruneOptions struct {
+	Confirm        bool          // Delete the dead bookmarks instead of only listing them
+	MinFailures    int           // Checks in a row a page must be gone
+	MinGoneAge     time.Duration // How long a page must have been gone
+	MaxGonePercent int           // Refuse to delete when more of the checked pages are gone
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+
+	pruneCmd.Flags().Bool("prune-dead", false, "Find bookmarks whose pages are gone (404/410 or unknown host)")
+	pruneCmd.Flags().Bool("confirm", false, "Actually delete the bookmarks from Linkding instead of only listing them")
+	pruneCmd.Flags().Int("min-failures", 3, "Number of checks in a row, at least the cache max age apart, a page must be gone before its bookmark is pruned")
+	pruneCmd.Flags().Duration("min-gone-age", 7*24*time.Hour, "How long a page must have been gone before its bookmark is pruned")
+	pruneCmd.Flags().Int("max-gone-percent", 20, "Refuse --confirm when more than this percentage of checked pages are gone on this run")
+	pruneCmd.Flags().StringSlice("tags", []string{}, "Comma-separated list of tags to limit which bookmarks are checked (empty = all bookmarks)")
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	pruneDead, _ := cmd.Flags().GetBool("prune-dead")
+	var opts PruneOptions
+	opts.Confirm, _ = cmd.Flags().GetBool("confirm")
+	opts.MinFailures, _ = cmd.Flags().GetInt("min-failures")
+	opts.MinGoneAge, _ = cmd.Flags().GetDuration("min-gone-age")
+	opts.MaxGonePercent, _ = cmd.Flags().GetInt("max-gone-percent")
+	if !pruneDead {
+		return fmt.Errorf("nothing to prune: pass --prune-dead to find bookmarks whose pages are gone")
+	}
+	if opts.MinFailures < 1 {
+		return fmt.Errorf("--min-failures must be at least 1")
+	}
+	if opts.MinGoneAge < 0 {
+		return fmt.Errorf("--min-gone-age cannot be negative")
+	}
+	if opts.MaxGonePercent < 0 || opts.MaxGonePercent > 100 {
+		return fmt.Errorf("--max-gone-percent must be between 0 and 100")
+	}
+
+	cfg, err := config.LoadConfig(viper.GetString("config"))
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if cmd.Flags().Changed("tags") {
+		cfg.Tags, _ = cmd.Flags().GetStringSlice("tags")
+	}
+
+	closeLog := cfg.SetupLogging()
+	defer closeLog()
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("configuration validation failed: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return Prune(ctx, cfg, opts, os.Stdout)
+}
+
+// Prune re-checks the bookmarks selected by cfg and lists, or with
+// opts.Confirm deletes, those whose pages have been gone long enough. cfg
+// must already be validated.
+func Prune(ctx context.Context, cfg *config.Config, opts PruneOptions, out io.Writer) error {
+	resultCache, err := cache.New(cfg.Cache.Backend, cfg.Cache.FilePath, cfg.Cache.Format, cfg.Cache.Compress)
+	if err != nil {
+		return err
+	}
+	if err := resultCache.LoadCache(); err != nil {
+		return fmt.Errorf("failed to load cache: %w", err)
+	}
+	defer resultCache.Close()
+
+	tlsConfig, err := cfg.TLSConfig()
+	if err != nil {
+		return fmt.Errorf("failed to configure TLS: %w", err)
+	}
+
+	linkdingClient, err := newLinkdingClient(cfg, tlsConfig)
+	if err != nil {
+		return err
+	}
+	if err := linkdingClient.Ping(); err != nil {
+		return fmt.Errorf("linkding preflight check failed: %w", err)
+	}
+
+	bookmarks, err := linkdingClient.FetchBookmarks(cfg.Tags)
+	if err != nil {
+		return fmt.Errorf("failed to fetch bookmarks: %w", err)
+	}
+	if len(bookmarks) == 0 {
+		fmt.Fprintln(out, "No bookmarks found. Nothing to prune.")
+		return nil
+	}
+
+	// Probe every page afresh so this run counts towards the gone streak
+	processingConfig := cfg.ProcessingConfig(tlsConfig)
+	processingConfig.NoCache = true
+	processingConfig.AddSkipOnFail = false
+
+	_, failed, stats := feeds.ProcessBookmarks(ctx, bookmarks, resultCache, processingConfig)
+	if stats.FatalError != nil {
+		return fmt.Errorf("stopped on the first hard error (--fail-fast): %w", stats.FatalError)
+	}
+	if stats.Interrupted {
+		return fmt.Errorf("prune interrupted after %d of %d bookmarks; nothing was deleted", stats.Processed, stats.TotalBookmarks-stats.DuplicateURLs)
+	}
+
+	// A resolver that fails every lookup, or a captive portal, makes every
+	// page look gone at once; real link rot never does
+	checked := stats.Processed - stats.Skipped - stats.SkippedScheme
+	goneNow := 0
+	for _, result := range failed {
+		if result.IsPageGone() {
+			goneNow++
+		}
+	}
+	tooManyGone := checked > 0 && goneNow*100 > opts.MaxGonePercent*checked
+
+	dead := findDeadBookmarks(bookmarks, failed, resultCache, cfg.Cache.MaxAge, opts.MinFailures, opts.MinGoneAge, time.Now())
+	if len(dead) == 0 {
+		if !cfg.Quiet {
+			fmt.Fprintf(out, "Checked %d bookmarks; none have been gone on %d checks in a row for %s.\n", checked, opts.MinFailures, opts.MinGoneAge)
+		}
+		return nil
+	}
+
+	for _, bookmark := range dead {
+		fmt.Fprintf(out, "  %s (%s)\n", bookmark.URL, bookmark.Title)
+	}
+
+	if tooManyGone {
+		reason := fmt.Sprintf("%d of %d checked pages are gone on this run, more than --max-gone-percent (%d%%), which usually means a DNS or network problem rather than dead sites",
+			goneNow, checked, opts.MaxGonePercent)
+		if opts.Confirm {
+			return fmt.Errorf("refusing to delete bookmarks: %s", reason)
+		}
+		fmt.Fprintf(out, "Warning: --confirm will be refused: %s.\n", reason)
+	}
+
+	if !opts.Confirm {
+		fmt.Fprintf(out, "%d bookmarks would be deleted. Re-run with --confirm to delete them.\n", len(dead))
+		return nil
+	}
+
+	var deleteErrs []error
+	deleted := 0
+	for _, bookmark := range dead {
+		if err := linkdingClient.DeleteBookmark(bookmark.ID); err != nil {
+			logrus.WithError(err).WithField("url", bookmark.URL).Error("Failed to delete bookmark")
+			deleteErrs = append(deleteErrs, err)
+			continue
+		}
+		logrus.WithFields(logrus.Fields{
+			"id":  bookmark.ID,
+			"url": bookmark.URL,
+		}).Info("Deleted bookmark whose page is gone")
+		deleted++
+	}
+
+	fmt.Fprintf(out, "Deleted %d of %d bookmarks.\n", deleted, len(dead))
+	return errors.Join(deleteErrs...)
+}
+
+// findDeadBookmarks returns the bookmarks whose pages were gone on this run,
+// on at least minFailures checks in a row and for at least minGoneAge,
+// according to the cache
+func findDeadBookmarks(bookmarks []*linkding.Bookmark, failed []*feeds.FeedDiscoveryResult, resultCache cache.Cache,
+	maxAgeHours, minFailures int, minGoneAge time.Duration, now time.Time,
+) []*linkding.Bookmark {
+	gone := make(map[string]bool)
+	for _, result := range failed {
+		if !result.IsPageGone() {
+			continue
+		}
+		entry := resultCache.Get(result.URL, maxAgeHours)
+		if entry == nil || entry.GoneCount < minFailures || entry.GoneSince.IsZero() || now.Sub(entry.GoneSince) < minGoneAge {
+			logrus.WithField("url", result.URL).Debug("Page is gone, but not for long enough to prune")
+			continue
+		}
+		gone[result.URL] = true
+	}
+
+	// Several bookmarks may share a URL; they are all dead together
+	var dead []*linkding.Bookmark
+	for _, bookmark := range bookmarks {
+		if gone[bookmark.URL] {
+			dead = append(dead, bookmark)
+		}
+	}
+	return dead
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lmorchard/linkding-to-opml/internal/cache"
+	"github.com/lmorchard/linkding-to-opml/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newGoneSite answers 410 Gone for every path under /gone and serves a page
+// without a feed everywhere else
+func newGoneSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/gone") {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>No feed here</title></head></html>`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newPruneConfig returns a validated config for the given Linkding server
+// whose cache already holds the given gone streaks
+func newPruneConfig(t *testing.T, apiURL string, streaks ...*cache.CacheEntry) *config.Config {
+	t.Helper()
+	cfg, err := config.DefaultConfig()
+	if err != nil {
+		t.Fatalf("DefaultConfig() error = %v", err)
+	}
+	cfg.Linkding.URL = apiURL
+	cfg.Linkding.Token = "secret"
+	cfg.Cache.FilePath = filepath.Join(t.TempDir(), "cache.gob")
+	cfg.Cache.MaxAge = 24
+	cfg.Discovery.CommonPathsDisabled = true
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	seed := cache.NewCache(cfg.Cache.FilePath)
+	for _, entry := range streaks {
+		seed.Put(entry)
+	}
+	if err := seed.SaveCache(); err != nil {
+		t.Fatalf("SaveCache() error = %v", err)
+	}
+	return cfg
+}
+
+func TestPruneDeletesLongGoneBookmarks(t *testing.T) {
+	logrus.SetOutput(io.Discard)
+	t.Cleanup(func() { logrus.SetOutput(os.Stderr) })
+
+	site := newGoneSite(t)
+	longGone := site.URL + "/gone/old"
+	recentlyGone := site.URL + "/gone/new"
+	api := newTestLinkding(t, "secret",
+		site.URL+"/a", longGone, site.URL+"/b", recentlyGone, site.URL+"/c",
+		site.URL+"/d", site.URL+"/e", site.URL+"/f", site.URL+"/g", site.URL+"/h")
+	now := time.Now()
+	cfg := newPruneConfig(t, api.URL,
+		&cache.CacheEntry{URL: longGone, GoneCount: 2, GoneSince: now.Add(-10 * 24 * time.Hour)},
+		// Gone on as many checks, but all of them within the last hour
+		&cache.CacheEntry{URL: recentlyGone, GoneCount: 2, GoneSince: now.Add(-time.Hour)},
+	)
+
+	opts := PruneOptions{Confirm: true, MinFailures: 3, MinGoneAge: 7 * 24 * time.Hour, MaxGonePercent: 20}
+	var out bytes.Buffer
+	if err := Prune(context.Background(), cfg, opts, &out); err != nil {
+		t.Fatalf("Prune() error = %v\n%s", err, out.String())
+	}
+	if got, want := api.Deleted(), []int{2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("deleted bookmarks = %v, want %v", got, want)
+	}
+	if !strings.Contains(out.String(), "Deleted 1 of 1 bookmarks.") {
+		t.Errorf("summary does not report the deletion:\n%s", out.String())
+	}
+
+	// The recent streak was not extended by a check within the cache max age
+	resultCache := cache.NewCache(cfg.Cache.FilePath)
+	if err := resultCache.LoadCache(); err != nil {
+		t.Fatalf("LoadCache() error = %v", err)
+	}
+	if entry := resultCache.Get(recentlyGone, cfg.Cache.MaxAge); entry == nil || entry.GoneCount != 2 {
+		t.Errorf("recently gone entry = %+v, want gone count 2", entry)
+	}
+}
+
+func TestPruneRefusesWhenTooManyPagesAreGone(t *testing.T) {
+	logrus.SetOutput(io.Discard)
+	t.Cleanup(func() { logrus.SetOutput(os.Stderr) })
+
+	site := newGoneSite(t)
+	api := newTestLinkding(t, "secret", site.URL+"/gone/a", site.URL+"/gone/b", site.URL+"/ok")
+	longAgo := time.Now().Add(-10 * 24 * time.Hour)
+	cfg := newPruneConfig(t, api.URL,
+		&cache.CacheEntry{URL: site.URL + "/gone/a", GoneCount: 5, GoneSince: longAgo},
+		&cache.CacheEntry{URL: site.URL + "/gone/b", GoneCount: 5, GoneSince: longAgo},
+	)
+
+	opts := PruneOptions{MinFailures: 3, MinGoneAge: 7 * 24 * time.Hour, MaxGonePercent: 20}
+	var out bytes.Buffer
+	if err := Prune(context.Background(), cfg, opts, &out); err != nil {
+		t.Fatalf("Prune() without --confirm error = %v", err)
+	}
+	if !strings.Contains(out.String(), "--confirm will be refused") {
+		t.Errorf("dry run does not warn about the refusal:\n%s", out.String())
+	}
+
+	opts.Confirm = true
+	err := Prune(context.Background(), cfg, opts, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "refusing to delete bookmarks") {
+		t.Errorf("Prune() error = %v, want a refusal", err)
+	}
+	if deleted := api.Deleted(); len(deleted) != 0 {
+		t.Errorf("deleted bookmarks = %v, want none", deleted)
+	}
+}
@@ -50,15 +50,19 @@ Configuration:
 func init() {
 	// Global flags
 	rootCmd.PersistentFlags().String("config", "", "Configuration file path (default: ./linkding-to-opml.yaml)")
+	rootCmd.PersistentFlags().String("profile", "", "Use the Linkding settings of this named profile from the config file")
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Enable verbose logging")
 	rootCmd.PersistentFlags().Bool("debug", false, "Enable debug logging")
 	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Suppress summary output (errors/warnings still shown)")
+	rootCmd.PersistentFlags().String("log-file", "", "Append log output to this file instead of the console")
 
 	// Bind global flags to viper
 	_ = viper.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config"))
+	_ = viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
 	_ = viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
 	_ = viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
 	_ = viper.BindPFlag("quiet", rootCmd.PersistentFlags().Lookup("quiet"))
+	_ = viper.BindPFlag("log_file", rootCmd.PersistentFlags().Lookup("log-file"))
 }
 
 // Execute runs the root command
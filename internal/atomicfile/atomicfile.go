@@ -0,0 +1,51 @@
+package atomicfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CreateTemp creates a temporary file in the directory of filePath, with the
+// given permissions, to be renamed over filePath once fully written. Being in
+// the same directory keeps the rename atomic, and the unique name keeps two
+// runs writing the same file from clobbering each other's temporary file.
+func CreateTemp(filePath string, perm os.FileMode) (*os.File, error) {
+	file, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	// CreateTemp always uses 0600
+	if err := file.Chmod(perm); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return file, nil
+}
+
+// WriteFile writes data to filePath like os.WriteFile, but by way of a
+// temporary file renamed over it, so readers see either the old contents or
+// the new ones and a failed write leaves the old file in place
+func WriteFile(filePath string, data []byte, perm os.FileMode) error {
+	file, err := CreateTemp(filePath, perm)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tempFile := file.Name()
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := os.Rename(tempFile, filePath); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+	return nil
+}
@@ -1,96 +1,254 @@
 package cache
 
 import (
+	"compress/gzip"
 	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/lmorchard/linkding-to-opml/internal/atomicfile"
+
 	"github.com/sirupsen/logrus"
 )
 
 // CacheEntry represents a single cached feed discovery result
 type CacheEntry struct {
-	URL       string    `json:"url"`
-	FeedURL   string    `json:"feed_url"`
-	FeedTitle string    `json:"feed_title"`
-	Timestamp time.Time `json:"timestamp"`
+	URL          string    `json:"url"`
+	FeedURL      string    `json:"feed_url"`
+	FeedTitle    string    `json:"feed_title"`
+	Language     string    `json:"language,omitempty"`
+	FeedType     string    `json:"feed_type,omitempty"`
+	IconURL      string    `json:"icon_url,omitempty"`
+	HubURL       string    `json:"hub_url,omitempty"`
+	AuthRequired bool      `json:"auth_required"` // Page answered 401/403 on the last attempt
+	Timestamp    time.Time `json:"timestamp"`
+
+	// Feed activity recorded at discovery time
+	ItemCount     int       `json:"item_count,omitempty"`
+	LastUpdated   time.Time `json:"last_updated"`
+	ActivityKnown bool      `json:"activity_known,omitempty"`
+
+	// Consecutive discoveries that found the page gone (404/410, unknown host),
+	// counting at most one per SetGone interval, and when the first of them ran
+	GoneCount int       `json:"gone_count,omitempty"`
+	GoneSince time.Time `json:"gone_since"`
+
+	// Cache lifetime the source asked for via Cache-Control max-age, replacing
+	// the configured max age; zero means none was given
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+}
+
+// maxAge returns how long the entry stays fresh: its own TTL when it has
+// one, otherwise maxAgeHours
+func (e *CacheEntry) maxAge(maxAgeHours int) time.Duration {
+	if e.TTLSeconds > 0 {
+		return time.Duration(e.TTLSeconds) * time.Second
+	}
+	return time.Duration(maxAgeHours) * time.Hour
+}
+
+// Cache file formats
+const (
+	FormatGob  = "gob"
+	FormatJSON = "json"
+)
+
+// Cache backends selectable with cache.backend
+const (
+	BackendFile   = "file"
+	BackendSQLite = "sqlite"
+)
+
+// Cache is a persistent store of feed discovery results keyed by bookmark URL
+type Cache interface {
+	// LoadCache opens the backing store and loads any existing entries
+	LoadCache() error
+	// SaveCache persists pending changes
+	SaveCache() error
+	// Close releases the backing store
+	Close() error
+
+	Get(url string, maxAgeHours int) *CacheEntry
+	Set(url, feedURL, feedTitle string)
+	Put(entry *CacheEntry)
+	SetFailed(url string)
+	SetAuthRequired(url string)
+	// SetGone stores a failed entry for a page that no longer exists, counting
+	// how many discoveries in a row have found it gone. A discovery only adds
+	// to the count once interval has passed since the previous one counted.
+	SetGone(url string, interval time.Duration)
+
+	// Prune removes entries older than maxAgeHours, or than their own TTL,
+	// and returns how many were removed
+	Prune(maxAgeHours int) int
+	// Stats returns the total number of entries and the number with a feed
+	Stats() (int, int)
+}
+
+// New creates a cache using the given backend: BackendFile (the default),
+// saved whole in the given format and gzip-compressed when compress is set,
+// or BackendSQLite, written incrementally
+func New(backend, filePath, format string, compress bool) (Cache, error) {
+	switch backend {
+	case "", BackendFile:
+		fileCache := NewCacheWithFormat(filePath, format)
+		fileCache.compress = compress
+		return fileCache, nil
+	case BackendSQLite:
+		return NewSQLiteCache(filePath), nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", backend)
+	}
 }
 
-// Cache manages the persistent cache of feed discovery results
-type Cache struct {
+// FileCache keeps all entries in memory and saves them to a single gob or
+// JSON file, optionally gzip-compressed, rewriting it on every save
+type FileCache struct {
 	mu       sync.RWMutex
 	entries  map[string]*CacheEntry
 	filePath string
+	format   string
+	compress bool // gzip the file on save; loading detects compression either way
+	readOnly bool // the file can't be written, so SaveCache does nothing
+}
+
+// NewCache creates a new cache instance that is saved as gob, or as JSON
+// when the file has a .json extension
+func NewCache(filePath string) *FileCache {
+	return NewCacheWithFormat(filePath, "")
 }
 
-// NewCache creates a new cache instance
-func NewCache(filePath string) *Cache {
-	return &Cache{
+// NewCacheWithFormat creates a new cache instance saved in the given format
+// (FormatGob or FormatJSON). An empty format picks one from the file
+// extension. Loading always detects the format, so switching formats
+// carries existing entries over.
+func NewCacheWithFormat(filePath, format string) *FileCache {
+	if format == "" {
+		format = FormatGob
+		if strings.EqualFold(filepath.Ext(filePath), ".json") {
+			format = FormatJSON
+		}
+	}
+
+	return &FileCache{
 		entries:  make(map[string]*CacheEntry),
 		filePath: filePath,
+		format:   format,
 	}
 }
 
 // LoadCache loads the cache from disk, creating a new cache if file doesn't exist or is corrupted
-func (c *Cache) LoadCache() error {
+func (c *FileCache) LoadCache() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	// Find out now rather than after a whole run that nothing can be saved
+	if err := checkWritable(c.filePath, false); err != nil {
+		if !isReadOnly(err) {
+			return fmt.Errorf("cache file %s can't be written: %w", c.filePath, err)
+		}
+		logrus.WithError(err).WithField("file", c.filePath).Warn("Cache file is not writable, results will only be cached in memory for this run")
+		c.readOnly = true
+	}
+
 	// Check if cache file exists
 	if _, err := os.Stat(c.filePath); os.IsNotExist(err) {
 		logrus.Debug("Cache file does not exist, starting with empty cache")
 		return nil
 	}
 
-	// Open cache file
-	file, err := os.Open(c.filePath)
+	// Read cache file
+	data, err := os.ReadFile(c.filePath)
 	if err != nil {
 		logrus.WithError(err).Warn("Failed to open cache file, starting with empty cache")
 		return nil
 	}
-	defer file.Close()
 
-	// Decode cache entries
-	decoder := gob.NewDecoder(file)
-	err = decoder.Decode(&c.entries)
+	// Decompress a gzipped cache, whatever the compress setting
+	data, compressed, err := decompressCacheData(data)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to decompress cache file (possibly corrupted), starting with empty cache")
+		return nil
+	}
+
+	// Decode cache entries and bring them up to the current version
+	entries, format, version, err := decodeCacheFile(data)
 	if err != nil {
 		logrus.WithError(err).Warn("Failed to decode cache file (possibly corrupted), starting with empty cache")
-		c.entries = make(map[string]*CacheEntry)
 		return nil
 	}
+	if entries == nil {
+		entries = make(map[string]*CacheEntry)
+	}
+	migrateEntries(entries, version)
+	c.entries = entries
 
 	logrus.WithFields(logrus.Fields{
-		"file":    c.filePath,
-		"entries": len(c.entries),
+		"file":       c.filePath,
+		"format":     format,
+		"compressed": compressed,
+		"version":    version,
+		"entries":    len(c.entries),
 	}).Debug("Successfully loaded cache from disk")
 
 	return nil
 }
 
 // SaveCache writes the cache to disk
-func (c *Cache) SaveCache() error {
+func (c *FileCache) SaveCache() error {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	if c.readOnly {
+		logrus.WithField("file", c.filePath).Debug("Not saving cache to read-only location")
+		return nil
+	}
+
 	// Create temporary file for atomic write
-	tempFile := c.filePath + ".tmp"
-	file, err := os.Create(tempFile)
+	file, err := atomicfile.CreateTemp(c.filePath, 0o644)
 	if err != nil {
 		return fmt.Errorf("failed to create temporary cache file: %w", err)
 	}
+	tempFile := file.Name()
+
+	var w io.Writer = file
+	var gz *gzip.Writer
+	if c.compress {
+		gz = gzip.NewWriter(file)
+		w = gz
+	}
 
-	// Encode cache entries
-	encoder := gob.NewEncoder(file)
-	err = encoder.Encode(c.entries)
+	// Encode cache entries in a versioned envelope
+	envelope := cacheFile{Version: CurrentVersion, Entries: c.entries}
+	if c.format == FormatJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(envelope)
+	} else {
+		err = gob.NewEncoder(w).Encode(envelope)
+	}
+	if err == nil && gz != nil {
+		// Closing flushes the compressed data and writes the gzip footer
+		err = gz.Close()
+	}
 	if err != nil {
 		file.Close()
 		os.Remove(tempFile)
 		return fmt.Errorf("failed to encode cache data: %w", err)
 	}
 
-	file.Close()
+	if err := file.Close(); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
 
 	// Atomically replace the old cache file
 	err = os.Rename(tempFile, c.filePath)
@@ -100,15 +258,52 @@ func (c *Cache) SaveCache() error {
 	}
 
 	logrus.WithFields(logrus.Fields{
-		"file":    c.filePath,
-		"entries": len(c.entries),
+		"file":       c.filePath,
+		"entries":    len(c.entries),
+		"compressed": c.compress,
 	}).Debug("Successfully saved cache to disk")
 
 	return nil
 }
 
+// checkWritable returns an error if a cache can't be saved at filePath,
+// creating its directory if needed. A file must be creatable next to it, for
+// the temporary file or database journal. With inPlace, an existing file must
+// itself be writable too.
+func checkWritable(filePath string, inPlace bool) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return err
+	}
+
+	probe, err := atomicfile.CreateTemp(filePath, 0o644)
+	if err != nil {
+		return err
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	if inPlace {
+		file, err := os.OpenFile(filePath, os.O_WRONLY, 0)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err == nil {
+			file.Close()
+		}
+	}
+	return nil
+}
+
+// isReadOnly reports whether a checkWritable error means the location is
+// read-only or off limits, as on a read-only mount, where the cache can still
+// be read. Anything else, such as a file where the directory should be, is a
+// misconfiguration worth stopping for.
+func isReadOnly(err error) bool {
+	return errors.Is(err, syscall.EROFS) || errors.Is(err, os.ErrPermission)
+}
+
 // Get retrieves a cached entry if it exists and is not stale
-func (c *Cache) Get(url string, maxAgeHours int) *CacheEntry {
+func (c *FileCache) Get(url string, maxAgeHours int) *CacheEntry {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -137,29 +332,32 @@ func (c *Cache) Get(url string, maxAgeHours int) *CacheEntry {
 }
 
 // Set stores a new cache entry
-func (c *Cache) Set(url, feedURL, feedTitle string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	entry := &CacheEntry{
+func (c *FileCache) Set(url, feedURL, feedTitle string) {
+	c.Put(&CacheEntry{
 		URL:       url,
 		FeedURL:   feedURL,
 		FeedTitle: feedTitle,
-		Timestamp: time.Now(),
-	}
+	})
+}
 
-	c.entries[url] = entry
+// Put stores a fully populated cache entry keyed by its URL, stamping it with the current time
+func (c *FileCache) Put(entry *CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.Timestamp = time.Now()
+	c.entries[entry.URL] = entry
 
 	logrus.WithFields(logrus.Fields{
-		"url":        url,
-		"feed_url":   feedURL,
-		"feed_title": feedTitle,
+		"url":        entry.URL,
+		"feed_url":   entry.FeedURL,
+		"feed_title": entry.FeedTitle,
 	}).Debug("Cached new feed discovery result")
 }
 
 // SetFailed stores a cache entry for a URL that failed feed discovery
 // This prevents repeated attempts for URLs that don't have feeds
-func (c *Cache) SetFailed(url string) {
+func (c *FileCache) SetFailed(url string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -175,14 +373,86 @@ func (c *Cache) SetFailed(url string) {
 	logrus.WithField("url", url).Debug("Cached failed feed discovery result")
 }
 
+// SetAuthRequired stores a cache entry for a URL whose page required authentication
+func (c *FileCache) SetAuthRequired(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[url] = &CacheEntry{
+		URL:          url,
+		AuthRequired: true,
+		Timestamp:    time.Now(),
+	}
+
+	logrus.WithField("url", url).Debug("Cached auth-required feed discovery result")
+}
+
+// SetGone stores a failed cache entry for a URL whose page no longer exists,
+// continuing the previous entry's gone streak (see nextGoneStreak). Any other
+// result resets the streak.
+func (c *FileCache) SetGone(url string, interval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	goneCount, goneSince := nextGoneStreak(c.entries[url], interval, now)
+	c.entries[url] = &CacheEntry{
+		URL:       url,
+		GoneCount: goneCount,
+		GoneSince: goneSince,
+		Timestamp: now,
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"url":        url,
+		"gone_count": goneCount,
+	}).Debug("Cached gone feed discovery result")
+}
+
+// nextGoneStreak returns the gone count and start time after a discovery at
+// now finds a page gone again. The count grows by one only when interval has
+// passed for each run already counted, so repeated runs during one outage
+// count once; a previous entry that wasn't gone starts a new streak.
+func nextGoneStreak(previous *CacheEntry, interval time.Duration, now time.Time) (int, time.Time) {
+	if previous == nil || previous.GoneCount == 0 || previous.GoneSince.IsZero() {
+		return 1, now
+	}
+	if now.Sub(previous.GoneSince) >= time.Duration(previous.GoneCount)*interval {
+		return previous.GoneCount + 1, previous.GoneSince
+	}
+	return previous.GoneCount, previous.GoneSince
+}
+
+// Prune removes entries older than maxAgeHours, or than their own TTL, and
+// returns how many were removed
+func (c *FileCache) Prune(maxAgeHours int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for url, entry := range c.entries {
+		if c.isStale(entry, maxAgeHours) {
+			delete(c.entries, url)
+			removed++
+		}
+	}
+
+	logrus.WithField("removed", removed).Debug("Pruned stale cache entries")
+	return removed
+}
+
+// Close is a no-op for the file cache, which holds no open resources
+func (c *FileCache) Close() error {
+	return nil
+}
+
 // isStale checks if a cache entry is older than the maximum allowed age
-func (c *Cache) isStale(entry *CacheEntry, maxAgeHours int) bool {
-	maxAge := time.Duration(maxAgeHours) * time.Hour
-	return time.Since(entry.Timestamp) > maxAge
+func (c *FileCache) isStale(entry *CacheEntry, maxAgeHours int) bool {
+	return time.Since(entry.Timestamp) > entry.maxAge(maxAgeHours)
 }
 
 // Stats returns cache statistics
-func (c *Cache) Stats() (int, int) {
+func (c *FileCache) Stats() (int, int) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
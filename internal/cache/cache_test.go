@@ -0,0 +1,239 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNextGoneStreak(t *testing.T) {
+	now := time.Now()
+	since := now.Add(-72 * time.Hour)
+	tests := []struct {
+		name      string
+		previous  *CacheEntry
+		wantCount int
+		wantSince time.Time
+	}{
+		{"first check", nil, 1, now},
+		{"previous result was not gone", &CacheEntry{FeedURL: "https://example.com/feed"}, 1, now},
+		{"streak without a start", &CacheEntry{GoneCount: 2}, 1, now},
+		{"next interval reached", &CacheEntry{GoneCount: 2, GoneSince: since}, 3, since},
+		{"within the current interval", &CacheEntry{GoneCount: 4, GoneSince: since}, 4, since},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, start := nextGoneStreak(tt.previous, 24*time.Hour, now)
+			if count != tt.wantCount || !start.Equal(tt.wantSince) {
+				t.Errorf("nextGoneStreak() = %d, %v; want %d, %v", count, start, tt.wantCount, tt.wantSince)
+			}
+		})
+	}
+}
+
+func TestSetGoneCountsOncePerInterval(t *testing.T) {
+	dir := t.TempDir()
+	backends := map[string]Cache{
+		BackendFile:   NewCache(filepath.Join(dir, "cache.gob")),
+		BackendSQLite: NewSQLiteCache(filepath.Join(dir, "cache.db")),
+	}
+	for name, c := range backends {
+		t.Run(name, func(t *testing.T) {
+			if err := c.LoadCache(); err != nil {
+				t.Fatalf("LoadCache() error = %v", err)
+			}
+			defer c.Close()
+
+			url := "https://example.com/gone"
+			for i := 0; i < 3; i++ {
+				c.SetGone(url, time.Hour)
+			}
+			entry := c.Get(url, 24)
+			if entry == nil || entry.GoneCount != 1 || entry.GoneSince.IsZero() {
+				t.Fatalf("entry after repeated runs = %+v, want gone count 1 with a start", entry)
+			}
+
+			// A run after the interval extends the streak from the same start
+			start := entry.GoneSince
+			c.SetGone(url, 0)
+			entry = c.Get(url, 24)
+			if entry == nil || entry.GoneCount != 2 || !entry.GoneSince.Equal(start) {
+				t.Errorf("entry after the interval = %+v, want gone count 2 since %v", entry, start)
+			}
+		})
+	}
+}
+
+func TestLoadCacheCreatesMissingDirectory(t *testing.T) {
+	for _, backend := range []string{BackendFile, BackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state", "linkding", "cache")
+			c, err := New(backend, path, "", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.LoadCache(); err != nil {
+				t.Fatalf("LoadCache() error = %v", err)
+			}
+			defer c.Close()
+
+			c.SetFailed("https://example.com/")
+			if err := c.SaveCache(); err != nil {
+				t.Fatalf("SaveCache() error = %v", err)
+			}
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("cache was not saved: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadCacheFailsOnUnusablePath(t *testing.T) {
+	// A regular file where the cache directory should be is a mistake to
+	// report, not a read-only location to fall back from
+	parent := filepath.Join(t.TempDir(), "not-a-directory")
+	if err := os.WriteFile(parent, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, backend := range []string{BackendFile, BackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			c, err := New(backend, filepath.Join(parent, "cache"), "", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.LoadCache(); err == nil {
+				c.Close()
+				t.Error("LoadCache() error = nil, want an error")
+			}
+		})
+	}
+}
+
+// newTestEntry returns a successful cache entry for url
+func newTestEntry(url string) *CacheEntry {
+	return &CacheEntry{
+		URL:       url,
+		FeedURL:   url + "feed.xml",
+		FeedTitle: "Example",
+		FeedType:  "rss",
+		HubURL:    "https://hub.example.com/",
+	}
+}
+
+func TestFileCacheCompressedRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatGob, FormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache")
+			saved := NewCacheWithFormat(path, format)
+			saved.compress = true
+			if err := saved.LoadCache(); err != nil {
+				t.Fatalf("LoadCache() error = %v", err)
+			}
+			saved.Put(newTestEntry("https://example.com/"))
+			if err := saved.SaveCache(); err != nil {
+				t.Fatalf("SaveCache() error = %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(data, gzipMagic) {
+				t.Fatalf("saved cache starts with %x, want gzip data", data[:min(len(data), 2)])
+			}
+
+			// Compression is detected on load, whatever the setting
+			loaded := NewCacheWithFormat(path, format)
+			if err := loaded.LoadCache(); err != nil {
+				t.Fatalf("LoadCache() error = %v", err)
+			}
+			entry := loaded.Get("https://example.com/", 24)
+			if entry == nil {
+				t.Fatal("Get() = nil, want the saved entry")
+			}
+			if want := newTestEntry("https://example.com/"); entry.FeedURL != want.FeedURL || entry.FeedTitle != want.FeedTitle || entry.HubURL != want.HubURL {
+				t.Errorf("entry = %+v, want %+v", entry, want)
+			}
+		})
+	}
+}
+
+func TestFileCacheLoadsUncompressedWhenCompressing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	plain := NewCache(path)
+	plain.Put(newTestEntry("https://example.com/"))
+	if err := plain.SaveCache(); err != nil {
+		t.Fatalf("SaveCache() error = %v", err)
+	}
+
+	compressing := NewCache(path)
+	compressing.compress = true
+	if err := compressing.LoadCache(); err != nil {
+		t.Fatalf("LoadCache() error = %v", err)
+	}
+	if compressing.Get("https://example.com/", 24) == nil {
+		t.Fatal("Get() = nil, want the entry from the uncompressed file")
+	}
+
+	// The next save switches the file over
+	if err := compressing.SaveCache(); err != nil {
+		t.Fatalf("SaveCache() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, gzipMagic) {
+		t.Error("re-saved cache is not compressed")
+	}
+}
+
+func TestFileCacheCorruptedGzipStartsEmpty(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write([]byte(strings.Repeat(`{"version": 5, "entries": {}}`, 100))); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]byte{
+		"truncated":      compressed.Bytes()[:compressed.Len()/2],
+		"garbage header": append(append([]byte(nil), gzipMagic...), "not really gzip"...),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache.gob")
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			c := NewCache(path)
+			if err := c.LoadCache(); err != nil {
+				t.Fatalf("LoadCache() error = %v, want a fresh cache", err)
+			}
+			if total, _ := c.Stats(); total != 0 {
+				t.Errorf("loaded %d entries from a corrupted file, want none", total)
+			}
+
+			// Saving replaces the corrupted file with a readable one
+			c.Put(newTestEntry("https://example.com/"))
+			if err := c.SaveCache(); err != nil {
+				t.Fatalf("SaveCache() error = %v", err)
+			}
+			reloaded := NewCache(path)
+			if err := reloaded.LoadCache(); err != nil {
+				t.Fatalf("LoadCache() error = %v", err)
+			}
+			if reloaded.Get("https://example.com/", 24) == nil {
+				t.Error("Get() = nil after re-saving, want the new entry")
+			}
+		})
+	}
+}
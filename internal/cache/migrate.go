@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CurrentVersion is the version of the on-disk cache layout written by
+// SaveCache. Bump it in the same change that alters CacheEntry, and add an
+// entry to migrations when older entries need rewriting; new fields whose
+// zero value is a sensible default need none.
+//
+// Version history:
+//
+//	0: bare map of entries, written before the file carried a version
+//	1: versioned envelope
+//	2: hub URL recorded per entry
+//	3: gone count recorded per entry
+//	4: per-entry TTL from Cache-Control max-age
+//	5: start of the gone streak recorded per entry
+const CurrentVersion = 5
+
+// cacheFile is the envelope the cache is stored in on disk
+type cacheFile struct {
+	Version int                    `json:"version"`
+	Entries map[string]*CacheEntry `json:"entries"`
+}
+
+// migrations upgrade entries from the version they're keyed by to the next one
+var migrations = map[int]func(entries map[string]*CacheEntry){
+	0: migrateV0,
+	4: migrateV4,
+}
+
+// migrateV0 fills in the feed type for entries cached before it was recorded.
+// Those feeds were always exported as RSS.
+func migrateV0(entries map[string]*CacheEntry) {
+	for _, entry := range entries {
+		if entry.HasFeed() && entry.FeedType == "" {
+			entry.FeedType = "rss"
+		}
+	}
+}
+
+// migrateV4 restarts gone streaks counted before their start was recorded.
+// Those counts went up on every run, so several runs during one outage could
+// add up to a streak long enough to prune.
+func migrateV4(entries map[string]*CacheEntry) {
+	for _, entry := range entries {
+		if entry.GoneCount > 0 && entry.GoneSince.IsZero() {
+			entry.GoneCount = 0
+		}
+	}
+}
+
+// gzipMagic is the header every gzip stream starts with
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompressCacheData returns the decompressed contents of gzipped cache
+// data, detected by its magic bytes, or data unchanged if it isn't gzipped.
+// It also reports whether the data was compressed.
+func decompressCacheData(data []byte) ([]byte, bool, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, false, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid gzip data: %w", err)
+	}
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid gzip data: %w", err)
+	}
+	return decompressed, true, nil
+}
+
+// decodeCacheFile decodes cache data in either format and layout, returning
+// the entries, the format and the version they were stored with
+func decodeCacheFile(data []byte) (map[string]*CacheEntry, string, int, error) {
+	// JSON first, since gob data is never valid JSON. A legacy bare map also
+	// decodes into the envelope without error, just with no entries.
+	var envelope cacheFile
+	if err := json.Unmarshal(data, &envelope); err == nil {
+		if envelope.Entries != nil || envelope.Version > 0 {
+			return envelope.Entries, FormatJSON, envelope.Version, nil
+		}
+		entries := make(map[string]*CacheEntry)
+		if err := json.Unmarshal(data, &entries); err == nil {
+			return entries, FormatJSON, 0, nil
+		}
+	}
+
+	envelope = cacheFile{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&envelope); err == nil {
+		return envelope.Entries, FormatGob, envelope.Version, nil
+	}
+
+	entries := make(map[string]*CacheEntry)
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entries); err != nil {
+		return nil, "", 0, fmt.Errorf("not a JSON or gob cache file: %w", err)
+	}
+	return entries, FormatGob, 0, nil
+}
+
+// migrateEntries upgrades entries stored with an older version to the current
+// layout. Entries from a newer version are used as-is.
+func migrateEntries(entries map[string]*CacheEntry, version int) {
+	if version > CurrentVersion {
+		logrus.WithFields(logrus.Fields{
+			"version":         version,
+			"current_version": CurrentVersion,
+		}).Warn("Cache file was written by a newer version; unknown fields will be dropped on save")
+		return
+	}
+
+	for v := version; v < CurrentVersion; v++ {
+		if migrate, ok := migrations[v]; ok {
+			migrate(entries)
+		}
+	}
+
+	if version < CurrentVersion {
+		logrus.WithFields(logrus.Fields{
+			"from_version": version,
+			"to_version":   CurrentVersion,
+			"entries":      len(entries),
+		}).Info("Migrated cache entries to the current version")
+	}
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadCacheMigratesVersion1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	v1 := `{"version": 1, "entries": {"https://example.com/": {` +
+		`"url": "https://example.com/", "feed_url": "https://example.com/feed", ` +
+		`"feed_title": "Example", "feed_type": "atom", "timestamp": "` +
+		time.Now().UTC().Format(time.RFC3339) + `"}}}`
+	if err := os.WriteFile(path, []byte(v1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCacheWithFormat(path, FormatJSON)
+	if err := c.LoadCache(); err != nil {
+		t.Fatalf("LoadCache() error = %v", err)
+	}
+
+	entry := c.Get("https://example.com/", 24)
+	if entry == nil {
+		t.Fatal("Get() = nil, want the version 1 entry")
+	}
+	if entry.FeedURL != "https://example.com/feed" || entry.FeedType != "atom" {
+		t.Errorf("entry = %+v, want feed URL and type kept", entry)
+	}
+	if entry.HubURL != "" || entry.GoneCount != 0 || entry.TTLSeconds != 0 {
+		t.Errorf("entry = %+v, want no hub, gone count or TTL", entry)
+	}
+
+	if err := c.SaveCache(); err != nil {
+		t.Fatalf("SaveCache() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved cacheFile
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("saved cache is not JSON: %v", err)
+	}
+	if saved.Version != CurrentVersion {
+		t.Errorf("saved version = %d, want %d", saved.Version, CurrentVersion)
+	}
+}
+
+func TestLoadCacheRestartsVersion4GoneStreaks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	v4 := `{"version": 4, "entries": {"https://example.com/": {` +
+		`"url": "https://example.com/", "gone_count": 5, "timestamp": "` +
+		time.Now().UTC().Format(time.RFC3339) + `"}}}`
+	if err := os.WriteFile(path, []byte(v4), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCacheWithFormat(path, FormatJSON)
+	if err := c.LoadCache(); err != nil {
+		t.Fatalf("LoadCache() error = %v", err)
+	}
+	if entry := c.Get("https://example.com/", 24); entry == nil || entry.GoneCount != 0 {
+		t.Errorf("entry = %+v, want the gone streak restarted", entry)
+	}
+}
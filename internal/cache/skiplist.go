@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SkipList is a user-maintained list of bookmark URLs that are known to have
+// no feed and should never be probed. The file holds one URL per line; blank
+// lines and lines starting with # are ignored.
+type SkipList struct {
+	mu       sync.RWMutex
+	urls     map[string]bool
+	filePath string
+}
+
+// LoadSkipList reads a skip-list file. A missing file yields an empty list
+// that will be created on the first Add.
+func LoadSkipList(filePath string) (*SkipList, error) {
+	skipList := &SkipList{
+		urls:     make(map[string]bool),
+		filePath: filePath,
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			logrus.WithField("file_path", filePath).Debug("Skip list file does not exist, starting empty")
+			return skipList, nil
+		}
+		return nil, fmt.Errorf("failed to open skip list file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		skipList.urls[line] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read skip list file: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"file_path": filePath,
+		"entries":   len(skipList.urls),
+	}).Info("Loaded skip list")
+
+	return skipList, nil
+}
+
+// Contains returns true if the URL is on the skip list
+func (s *SkipList) Contains(url string) bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.urls[url]
+}
+
+// Add appends a URL to the skip list and its file, if it isn't listed already
+func (s *SkipList) Add(url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.urls[url] {
+		return nil
+	}
+
+	file, err := os.OpenFile(s.filePath, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open skip list file: %w", err)
+	}
+	defer file.Close()
+
+	// Hand-edited files may lack a trailing newline
+	line := url + "\n"
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			line = "\n" + line
+		}
+	}
+
+	if _, err := file.WriteString(line); err != nil {
+		return fmt.Errorf("failed to append to skip list file: %w", err)
+	}
+
+	s.urls[url] = true
+	logrus.WithField("url", url).Debug("Added URL to skip list")
+
+	return nil
+}
+
+// Len returns the number of URLs on the skip list
+func (s *SkipList) Len() int {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.urls)
+}
@@ -0,0 +1,341 @@
+package cache
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	_ "modernc.org/sqlite" // registers the cgo-free "sqlite" driver
+)
+
+// sqliteSchema creates the entries table. The URL primary key doubles as the
+// lookup index.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS entries (
+	url            TEXT PRIMARY KEY,
+	feed_url       TEXT NOT NULL DEFAULT '',
+	feed_title     TEXT NOT NULL DEFAULT '',
+	language       TEXT NOT NULL DEFAULT '',
+	feed_type      TEXT NOT NULL DEFAULT '',
+	icon_url       TEXT NOT NULL DEFAULT '',
+	hub_url        TEXT NOT NULL DEFAULT '',
+	auth_required  INTEGER NOT NULL DEFAULT 0,
+	timestamp      INTEGER NOT NULL,
+	item_count     INTEGER NOT NULL DEFAULT 0,
+	last_updated   INTEGER NOT NULL DEFAULT 0,
+	activity_known INTEGER NOT NULL DEFAULT 0,
+	gone_count     INTEGER NOT NULL DEFAULT 0,
+	ttl_seconds    INTEGER NOT NULL DEFAULT 0,
+	gone_since     INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS entries_timestamp ON entries (timestamp);
+`
+
+// sqliteColumns lists the entry columns in the order scanEntry expects
+const sqliteColumns = `url, feed_url, feed_title, language, feed_type, icon_url, hub_url,
+	auth_required, timestamp, item_count, last_updated, activity_known, gone_count, ttl_seconds, gone_since`
+
+// SQLiteCache stores entries in a SQLite database, looking them up on demand
+// and writing each change as it happens instead of rewriting the whole cache.
+// It suits very large bookmark collections.
+type SQLiteCache struct {
+	db       *sql.DB
+	filePath string
+}
+
+// NewSQLiteCache creates a SQLite-backed cache; the database is opened by LoadCache
+func NewSQLiteCache(filePath string) *SQLiteCache {
+	return &SQLiteCache{filePath: filePath}
+}
+
+// LoadCache opens the database, creating it and its schema if needed. A
+// database that can't be written is copied into memory instead, so the run
+// still goes ahead with the entries that are already cached.
+func (c *SQLiteCache) LoadCache() error {
+	if err := checkWritable(c.filePath, true); err != nil {
+		if !isReadOnly(err) {
+			return fmt.Errorf("cache database %s can't be written: %w", c.filePath, err)
+		}
+		logrus.WithError(err).WithField("file", c.filePath).Warn("Cache database is not writable, results will only be cached in memory for this run")
+		return c.loadInMemory()
+	}
+
+	db, err := openSQLiteCache(c.filePath)
+	if err != nil {
+		return err
+	}
+	c.db = db
+
+	total, _ := c.Stats()
+	logrus.WithFields(logrus.Fields{
+		"file":    c.filePath,
+		"entries": total,
+	}).Debug("Opened SQLite cache")
+
+	return nil
+}
+
+// loadInMemory opens an in-memory database holding a copy of the entries in
+// the database file, if it exists and can be read
+func (c *SQLiteCache) loadInMemory() error {
+	db, err := openSQLiteCache(":memory:")
+	if err != nil {
+		return err
+	}
+	c.db = db
+
+	if _, err := os.Stat(c.filePath); err != nil {
+		return nil
+	}
+	source := (&url.URL{Scheme: "file", Path: c.filePath, RawQuery: "mode=ro&immutable=1"}).String()
+	if _, err := db.Exec(`ATTACH DATABASE ? AS disk`, source); err != nil {
+		logrus.WithError(err).Warn("Failed to read cache database, starting with empty cache")
+		return nil
+	}
+	if _, err := db.Exec(`INSERT INTO entries (` + sqliteColumns + `) SELECT ` + sqliteColumns + ` FROM disk.entries`); err != nil {
+		logrus.WithError(err).Warn("Failed to copy cache database into memory, starting with empty cache")
+	}
+	if _, err := db.Exec(`DETACH DATABASE disk`); err != nil {
+		logrus.WithError(err).Debug("Failed to detach cache database")
+	}
+
+	total, _ := c.Stats()
+	logrus.WithFields(logrus.Fields{
+		"file":    c.filePath,
+		"entries": total,
+	}).Debug("Copied SQLite cache into memory")
+
+	return nil
+}
+
+// openSQLiteCache opens the database at dataSource and brings its schema up to date
+func openSQLiteCache(dataSource string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dataSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache database: %w", err)
+	}
+	// One connection serializes writes from concurrent workers, avoiding
+	// SQLITE_BUSY, and keeps an in-memory database alive between queries
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(`PRAGMA journal_mode = WAL`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to configure cache database: %w", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create cache schema: %w", err)
+	}
+	if err := addMissingColumns(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate cache schema: %w", err)
+	}
+	return db, nil
+}
+
+// sqliteAddedColumns are columns added after the initial schema, with the
+// definitions used to add them to databases created before they existed
+var sqliteAddedColumns = []struct {
+	name       string
+	definition string
+}{
+	{"hub_url", "TEXT NOT NULL DEFAULT ''"},
+	{"gone_count", "INTEGER NOT NULL DEFAULT 0"},
+	{"ttl_seconds", "INTEGER NOT NULL DEFAULT 0"},
+	{"gone_since", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// addMissingColumns brings a database created by an older version up to the current schema
+func addMissingColumns(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('entries')`)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, column := range sqliteAddedColumns {
+		if existing[column.name] {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE entries ADD COLUMN ` + column.name + ` ` + column.definition); err != nil {
+			return err
+		}
+		logrus.WithField("column", column.name).Info("Added column to SQLite cache")
+	}
+
+	return nil
+}
+
+// SaveCache is a no-op: every change is written when it is made
+func (c *SQLiteCache) SaveCache() error {
+	return nil
+}
+
+// Close closes the database
+func (c *SQLiteCache) Close() error {
+	if c.db == nil {
+		return nil
+	}
+	return c.db.Close()
+}
+
+// Get retrieves a cached entry if it exists and is not stale
+func (c *SQLiteCache) Get(url string, maxAgeHours int) *CacheEntry {
+	row := c.db.QueryRow(`SELECT `+sqliteColumns+` FROM entries WHERE url = ?`, url)
+	entry, err := scanEntry(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		logrus.WithField("url", url).Debug("Cache miss: no entry found")
+		return nil
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("url", url).Warn("Failed to read cache entry")
+		return nil
+	}
+
+	if time.Since(entry.Timestamp) > entry.maxAge(maxAgeHours) {
+		logrus.WithFields(logrus.Fields{
+			"url": url,
+			"age": time.Since(entry.Timestamp),
+		}).Debug("Cache miss: entry is stale")
+		return nil
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"url":        url,
+		"feed_url":   entry.FeedURL,
+		"feed_title": entry.FeedTitle,
+		"age":        time.Since(entry.Timestamp),
+	}).Debug("Cache hit: returning fresh entry")
+
+	return entry
+}
+
+// Set stores a new cache entry
+func (c *SQLiteCache) Set(url, feedURL, feedTitle string) {
+	c.Put(&CacheEntry{
+		URL:       url,
+		FeedURL:   feedURL,
+		FeedTitle: feedTitle,
+	})
+}
+
+// Put stores a fully populated cache entry keyed by its URL, stamping it with the current time
+func (c *SQLiteCache) Put(entry *CacheEntry) {
+	entry.Timestamp = time.Now()
+
+	var lastUpdated, goneSince int64
+	if !entry.LastUpdated.IsZero() {
+		lastUpdated = entry.LastUpdated.UnixNano()
+	}
+	if !entry.GoneSince.IsZero() {
+		goneSince = entry.GoneSince.UnixNano()
+	}
+
+	_, err := c.db.Exec(`INSERT OR REPLACE INTO entries (`+sqliteColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.URL, entry.FeedURL, entry.FeedTitle, entry.Language, entry.FeedType, entry.IconURL, entry.HubURL,
+		entry.AuthRequired, entry.Timestamp.UnixNano(), entry.ItemCount, lastUpdated, entry.ActivityKnown, entry.GoneCount,
+		entry.TTLSeconds, goneSince)
+	if err != nil {
+		logrus.WithError(err).WithField("url", entry.URL).Warn("Failed to write cache entry")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"url":        entry.URL,
+		"feed_url":   entry.FeedURL,
+		"feed_title": entry.FeedTitle,
+	}).Debug("Cached new feed discovery result")
+}
+
+// SetFailed stores a cache entry for a URL that failed feed discovery
+func (c *SQLiteCache) SetFailed(url string) {
+	c.Put(&CacheEntry{URL: url})
+}
+
+// SetAuthRequired stores a cache entry for a URL whose page required authentication
+func (c *SQLiteCache) SetAuthRequired(url string) {
+	c.Put(&CacheEntry{URL: url, AuthRequired: true})
+}
+
+// SetGone stores a failed cache entry for a URL whose page no longer exists,
+// continuing the previous entry's gone streak (see nextGoneStreak). Any other
+// result resets the streak.
+func (c *SQLiteCache) SetGone(url string, interval time.Duration) {
+	previous, err := scanEntry(c.db.QueryRow(`SELECT `+sqliteColumns+` FROM entries WHERE url = ?`, url))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logrus.WithError(err).WithField("url", url).Warn("Failed to read cache entry")
+	}
+
+	goneCount, goneSince := nextGoneStreak(previous, interval, time.Now())
+	c.Put(&CacheEntry{URL: url, GoneCount: goneCount, GoneSince: goneSince})
+}
+
+// Prune removes entries older than maxAgeHours, or than their own TTL, and
+// returns how many were removed
+func (c *SQLiteCache) Prune(maxAgeHours int) int {
+	now := time.Now()
+	cutoff := now.Add(-time.Duration(maxAgeHours) * time.Hour)
+	result, err := c.db.Exec(`DELETE FROM entries WHERE CASE
+		WHEN ttl_seconds > 0 THEN timestamp + ttl_seconds * 1000000000 < ?
+		ELSE timestamp < ?
+	END`, now.UnixNano(), cutoff.UnixNano())
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to prune cache entries")
+		return 0
+	}
+
+	removed, _ := result.RowsAffected()
+	logrus.WithField("removed", removed).Debug("Pruned stale cache entries")
+	return int(removed)
+}
+
+// Stats returns the total number of entries and the number with a feed
+func (c *SQLiteCache) Stats() (int, int) {
+	var total, successful int
+	err := c.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(feed_url != ''), 0) FROM entries`).Scan(&total, &successful)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to count cache entries")
+		return 0, 0
+	}
+	return total, successful
+}
+
+// scanEntry reads a row selected with sqliteColumns into a CacheEntry
+func scanEntry(row *sql.Row) (*CacheEntry, error) {
+	var entry CacheEntry
+	var timestamp, lastUpdated, goneSince int64
+
+	err := row.Scan(&entry.URL, &entry.FeedURL, &entry.FeedTitle, &entry.Language, &entry.FeedType, &entry.IconURL, &entry.HubURL,
+		&entry.AuthRequired, &timestamp, &entry.ItemCount, &lastUpdated, &entry.ActivityKnown, &entry.GoneCount,
+		&entry.TTLSeconds, &goneSince)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Timestamp = time.Unix(0, timestamp)
+	if lastUpdated != 0 {
+		entry.LastUpdated = time.Unix(0, lastUpdated)
+	}
+	if goneSince != 0 {
+		entry.GoneSince = time.Unix(0, goneSince)
+	}
+
+	return &entry, nil
+}
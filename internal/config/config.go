@@ -1,8 +1,14 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -13,54 +19,269 @@ import (
 type Config struct {
 	// Linkding API settings
 	Linkding struct {
-		Token   string        `mapstructure:"token"`
-		URL     string        `mapstructure:"url"`
-		Timeout time.Duration `mapstructure:"timeout"`
+		Token     string        `mapstructure:"token"`
+		TokenFile string        `mapstructure:"token_file"` // read the token from a file instead of inline
+		URL       string        `mapstructure:"url"`
+		Timeout   time.Duration `mapstructure:"timeout"`
 	} `mapstructure:"linkding"`
 
+	// Named Linkding instances; the selected one overrides the linkding section
+	Profiles       map[string]LinkdingProfile `mapstructure:"profiles"`
+	DefaultProfile string                     `mapstructure:"default_profile"`
+	Profile        string                     `mapstructure:"profile"` // set with --profile
+
 	// Cache settings
 	Cache struct {
+		Backend  string `mapstructure:"backend"` // file or sqlite
 		FilePath string `mapstructure:"file_path"`
-		MaxAge   int    `mapstructure:"max_age"` // in hours
+		Format   string `mapstructure:"format"`   // gob or json; empty picks by file extension
+		Compress bool   `mapstructure:"compress"` // gzip the file backend's cache file
+		MaxAge   int    `mapstructure:"max_age"`  // in hours
+		Disabled bool   `mapstructure:"disabled"`
+		Verify   bool   `mapstructure:"verify"` // re-fetch cached feeds before trusting them
+
+		AuthRequiredMaxAge int `mapstructure:"auth_required_max_age"` // in hours
+
+		// Bounds on the Cache-Control max-age honored per entry; zero MaxTTL
+		// means MaxAge
+		MinTTL time.Duration `mapstructure:"min_ttl"`
+		MaxTTL time.Duration `mapstructure:"max_ttl"`
 	} `mapstructure:"cache"`
 
+	// Persistent list of bookmark URLs that never have feeds
+	SkipList struct {
+		FilePath  string `mapstructure:"file_path"`
+		AddOnFail bool   `mapstructure:"add_on_fail"`
+	} `mapstructure:"skip_list"`
+
 	// HTTP client settings
 	HTTP struct {
 		Timeout      time.Duration `mapstructure:"timeout"`
 		UserAgent    string        `mapstructure:"user_agent"`
 		MaxRedirects int           `mapstructure:"max_redirects"`
+		MaxBodyBytes int64         `mapstructure:"max_body_bytes"`
+		MaxPerHost   int           `mapstructure:"max_per_host"` // simultaneous requests to one host, 0 = unlimited
+
+		// Pool of user agents rotated across discoveries when UserAgentRotate is set
+		UserAgents      []string `mapstructure:"user_agents"`
+		UserAgentRotate bool     `mapstructure:"user_agent_rotate"`
+
+		// Retry soft-blocked pages (an error page served with a 200) with a browser user agent
+		RetryBlocked bool `mapstructure:"retry_blocked"`
+
+		// Retries for transient bookmark page fetch failures, with the same
+		// backoff and Retry-After handling as feed_fetch
+		RetryAttempts    int           `mapstructure:"retry_attempts"`
+		RetryBaseBackoff time.Duration `mapstructure:"retry_base_backoff"`
+		RetryMaxBackoff  time.Duration `mapstructure:"retry_max_backoff"`
+		RetryAfterMax    time.Duration `mapstructure:"retry_after_max"`
+
+		// Per-phase timeouts; Timeout above still bounds the whole request
+		DialTimeout           time.Duration `mapstructure:"dial_timeout"`
+		TLSHandshakeTimeout   time.Duration `mapstructure:"tls_handshake_timeout"`
+		ResponseHeaderTimeout time.Duration `mapstructure:"response_header_timeout"`
+
+		// TLS settings for self-hosted services behind private CAs
+		InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+		CACertFile         string `mapstructure:"ca_cert_file"`
+
+		// Proxy URL for all outbound requests (http, https, socks5, socks5h)
+		Proxy string `mapstructure:"proxy"`
+
+		// Extra request headers for feed discovery, globally and per hostname
+		Headers     map[string]string `mapstructure:"headers"`
+		HostHeaders []HostHeaders     `mapstructure:"host_headers"`
+
+		// HTTP Basic Auth credentials for feed discovery, per hostname
+		BasicAuth []HostBasicAuth `mapstructure:"basic_auth"`
 	} `mapstructure:"http"`
 
+	// Candidate feed fetch settings (zero values fall back to the HTTP settings)
+	FeedFetch struct {
+		MaxRedirects  int `mapstructure:"max_redirects"`
+		RetryAttempts int `mapstructure:"retry_attempts"`
+
+		// Exponential backoff between retries, doubling from the base up to the max
+		RetryBaseBackoff time.Duration `mapstructure:"retry_base_backoff"`
+		RetryMaxBackoff  time.Duration `mapstructure:"retry_max_backoff"`
+
+		// Longest Retry-After delay honored; a longer one stops the retries
+		RetryAfterMax time.Duration `mapstructure:"retry_after_max"`
+	} `mapstructure:"feed_fetch"`
+
+	// Feed discovery settings
+	Discovery struct {
+		CommonPaths         []string `mapstructure:"common_paths"`
+		CommonPathsMode     string   `mapstructure:"common_paths_mode"` // append or replace
+		CommonPathsDisabled bool     `mapstructure:"common_paths_disabled"`
+		TitleFallback       []string `mapstructure:"title_fallback"` // bookmark, page, domain; tried in order
+	} `mapstructure:"discovery"`
+
 	// Output settings
 	Output string `mapstructure:"output"`
+	Append bool   `mapstructure:"append"`
+	Merge  bool   `mapstructure:"merge"` // like Append, but only adds feeds not already in the file
+	Backup bool   `mapstructure:"backup"`
+	Stream bool   `mapstructure:"stream"` // write outlines as feeds are discovered
+
+	IncludeUnreachable bool   `mapstructure:"include_unreachable"`
+	IncludeIcons       bool   `mapstructure:"include_icons"` // non-standard iconUrl outline attribute
+	IncludeCategories  bool   `mapstructure:"include_categories"`
+	Sort               string `mapstructure:"sort"`
+	OPMLVersion        string `mapstructure:"opml_version"` // 1.0 or 2.0
+	OPMLTextSource     string `mapstructure:"opml_text_source"`
+	Deterministic      bool   `mapstructure:"deterministic"` // no head timestamps, outlines sorted by URL
+
+	// Safety guards against replacing a good export with a truncated one
+	MinFeeds         int `mapstructure:"min_feeds"`
+	MaxShrinkPercent int `mapstructure:"max_shrink_percent"`
+
+	MaxFeedsPerDomain int `mapstructure:"max_feeds_per_domain"` // 0 = unlimited
+
+	// Leave out feeds that are already known, for incremental migrations
+	DedupeAgainstExisting bool   `mapstructure:"dedupe_against_existing"` // feed URLs bookmarked in Linkding
+	DedupeAgainstOPML     string `mapstructure:"dedupe_against_opml"`     // xmlUrls of an existing OPML file
 
 	// Processing settings
 	Tags        []string `mapstructure:"tags"`
+	Since       string   `mapstructure:"since"` // duration (e.g. 72h, 7d) or RFC3339 timestamp
 	Concurrency int      `mapstructure:"concurrency"`
 
+	AdaptiveConcurrency bool `mapstructure:"adaptive_concurrency"` // treat Concurrency as a ceiling and tune below it
+
+	Deadline time.Duration `mapstructure:"deadline"` // overall time limit for discovery; zero means none
+
+	ValidateFeeds bool `mapstructure:"validate_feeds"` // report common problems in discovered feeds
+	FailFast      bool `mapstructure:"fail_fast"`      // stop at the first error every bookmark would hit
+
+	// Completion notification settings
+	Notify struct {
+		Webhook string `mapstructure:"webhook"` // URL that receives a JSON run summary
+		On      string `mapstructure:"on"`      // always or failure
+	} `mapstructure:"notify"`
+
+	MetricsFile string `mapstructure:"metrics_file"` // Prometheus textfile written after each run
+
+	ExportFailures string `mapstructure:"export_failures"` // URL and error of each failed bookmark, .csv or text
+
+	DomainStats     bool   `mapstructure:"domain_stats"` // print discovery results by domain in the summary
+	DomainStatsFile string `mapstructure:"domain_stats_file"`
+
 	// Logging settings
 	Verbose bool `mapstructure:"verbose"`
 	Debug   bool `mapstructure:"debug"`
 	Quiet   bool `mapstructure:"quiet"`
 
+	LogFile string `mapstructure:"log_file"`
+
 	// Debug settings
 	SaveFailedHTML bool   `mapstructure:"save_failed_html"`
 	DebugOutputDir string `mapstructure:"debug_output_dir"`
 }
 
+// LinkdingProfile holds the Linkding settings of one named instance
+type LinkdingProfile struct {
+	Token     string        `mapstructure:"token"`
+	TokenFile string        `mapstructure:"token_file"`
+	URL       string        `mapstructure:"url"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+}
+
+// HostHeaders holds extra request headers for a single hostname. Hosts are
+// listed rather than used as map keys because viper splits keys on dots.
+type HostHeaders struct {
+	Host    string            `mapstructure:"host"`
+	Headers map[string]string `mapstructure:"headers"`
+}
+
+// HostBasicAuth holds the HTTP Basic Auth credentials for a single hostname.
+// They are only sent over https unless AllowHTTP is set.
+type HostBasicAuth struct {
+	Host      string `mapstructure:"host"`
+	Username  string `mapstructure:"username"`
+	Password  string `mapstructure:"password"`
+	AllowHTTP bool   `mapstructure:"allow_http"`
+}
+
+// setDefaults registers the default value of every setting on v
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("cache.file_path", "./linkding-to-opml.gob")
+	v.SetDefault("cache.backend", "file")
+	v.SetDefault("cache.format", "")
+	v.SetDefault("cache.compress", false)
+	v.SetDefault("cache.max_age", 720) // 30 days in hours
+	v.SetDefault("cache.disabled", false)
+	v.SetDefault("cache.verify", false)
+	v.SetDefault("cache.auth_required_max_age", 24)
+	v.SetDefault("cache.min_ttl", "1h")
+	v.SetDefault("cache.max_ttl", "0s")
+	v.SetDefault("skip_list.file_path", "")
+	v.SetDefault("skip_list.add_on_fail", false)
+	v.SetDefault("output", "feeds.opml")
+	v.SetDefault("append", false)
+	v.SetDefault("merge", false)
+	v.SetDefault("backup", false)
+	v.SetDefault("stream", false)
+	v.SetDefault("include_unreachable", false)
+	v.SetDefault("include_icons", false)
+	v.SetDefault("include_categories", false)
+	v.SetDefault("sort", "none")
+	v.SetDefault("opml_version", "2.0")
+	v.SetDefault("opml_text_source", "title")
+	v.SetDefault("deterministic", false)
+	v.SetDefault("min_feeds", 0)
+	v.SetDefault("max_feeds_per_domain", 0)
+	v.SetDefault("dedupe_against_existing", false)
+	v.SetDefault("dedupe_against_opml", "")
+	v.SetDefault("max_shrink_percent", 0)
+	v.SetDefault("since", "")
+	v.SetDefault("concurrency", 16)
+	v.SetDefault("adaptive_concurrency", false)
+	v.SetDefault("deadline", "0s")
+	v.SetDefault("validate_feeds", false)
+	v.SetDefault("fail_fast", false)
+	v.SetDefault("notify.webhook", "")
+	v.SetDefault("notify.on", "always")
+	v.SetDefault("metrics_file", "")
+	v.SetDefault("export_failures", "")
+	v.SetDefault("domain_stats", false)
+	v.SetDefault("domain_stats_file", "")
+	v.SetDefault("http.timeout", "30s")
+	v.SetDefault("http.user_agent", "Mozilla/5.0 (compatible; linkding-to-opml/1.0)")
+	v.SetDefault("http.max_redirects", 3)
+	v.SetDefault("http.max_body_bytes", 10*1024*1024) // 10 MB
+	v.SetDefault("http.max_per_host", 4)
+	v.SetDefault("http.user_agent_rotate", false)
+	v.SetDefault("http.retry_blocked", false)
+	v.SetDefault("http.retry_attempts", 0)
+	v.SetDefault("http.retry_base_backoff", "1s")
+	v.SetDefault("http.retry_max_backoff", "30s")
+	v.SetDefault("http.retry_after_max", "2m")
+	v.SetDefault("http.dial_timeout", "10s")
+	v.SetDefault("http.tls_handshake_timeout", "10s")
+	v.SetDefault("http.response_header_timeout", "15s")
+	v.SetDefault("http.insecure_skip_verify", false)
+	v.SetDefault("http.ca_cert_file", "")
+	v.SetDefault("http.proxy", "")
+	v.SetDefault("feed_fetch.max_redirects", 0)
+	v.SetDefault("feed_fetch.retry_attempts", 0)
+	v.SetDefault("feed_fetch.retry_base_backoff", "1s")
+	v.SetDefault("feed_fetch.retry_max_backoff", "30s")
+	v.SetDefault("feed_fetch.retry_after_max", "2m")
+	v.SetDefault("discovery.common_paths_mode", "append")
+	v.SetDefault("discovery.common_paths_disabled", false)
+	v.SetDefault("discovery.title_fallback", []string{})
+	v.SetDefault("linkding.token", "")
+	v.SetDefault("linkding.token_file", "")
+	v.SetDefault("linkding.timeout", "30s")
+	v.SetDefault("save_failed_html", false)
+	v.SetDefault("debug_output_dir", "./debug")
+}
+
 // LoadConfig loads configuration from file and merges with command-line flags
 func LoadConfig(configFile string) (*Config, error) {
 	// Set defaults
-	viper.SetDefault("cache.file_path", "./linkding-to-opml.gob")
-	viper.SetDefault("cache.max_age", 720) // 30 days in hours
-	viper.SetDefault("output", "feeds.opml")
-	viper.SetDefault("concurrency", 16)
-	viper.SetDefault("http.timeout", "30s")
-	viper.SetDefault("http.user_agent", "Mozilla/5.0 (compatible; linkding-to-opml/1.0)")
-	viper.SetDefault("http.max_redirects", 3)
-	viper.SetDefault("linkding.timeout", "30s")
-	viper.SetDefault("save_failed_html", false)
-	viper.SetDefault("debug_output_dir", "./debug")
+	setDefaults(viper.GetViper())
 
 	// Set config file
 	if configFile != "" {
@@ -81,9 +302,15 @@ func LoadConfig(configFile string) (*Config, error) {
 	}
 
 	// Bind environment variables with prefix
+	// Nested keys map to underscores, e.g. LINKDING_TO_OPML_LINKDING_TOKEN
 	viper.SetEnvPrefix("LINKDING_TO_OPML")
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
 
+	if err := applyProfile(viper.GetViper()); err != nil {
+		return nil, err
+	}
+
 	var config Config
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("error unmarshalling config: %w", err)
@@ -92,26 +319,466 @@ func LoadConfig(configFile string) (*Config, error) {
 	return &config, nil
 }
 
-// Validate checks that required configuration is present
+// applyProfile merges the Linkding settings of the profile selected with
+// --profile (or default_profile) over the config file's linkding section.
+// Linkding flags and environment variables still take precedence.
+func applyProfile(v *viper.Viper) error {
+	name := v.GetString("profile")
+	if name == "" {
+		name = v.GetString("default_profile")
+	}
+	if name == "" {
+		return nil
+	}
+
+	// Viper lowercases keys, so profile names are case-insensitive
+	profiles := v.GetStringMap("profiles")
+	profile, ok := profiles[strings.ToLower(name)].(map[string]interface{})
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for profileName := range profiles {
+			names = append(names, profileName)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("profile %q is selected but no profiles are configured", name)
+		}
+		return fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(names, ", "))
+	}
+
+	// A profile's token source replaces the linkding section's rather than
+	// adding a second one
+	merged := make(map[string]interface{}, len(profile)+1)
+	for key, value := range profile {
+		merged[key] = value
+	}
+	_, hasToken := profile["token"]
+	_, hasTokenFile := profile["token_file"]
+	if hasToken && !hasTokenFile {
+		merged["token_file"] = ""
+	}
+	if hasTokenFile && !hasToken {
+		merged["token"] = ""
+	}
+
+	if err := v.MergeConfigMap(map[string]interface{}{"linkding": merged}); err != nil {
+		return fmt.Errorf("failed to apply profile %q: %w", name, err)
+	}
+
+	logrus.WithField("profile", name).Debug("Using Linkding settings from profile")
+	return nil
+}
+
+// resolveToken fills in the Linkding token from linkding.token_file. Exactly
+// one token source may be used, so an inline token (flag, config or
+// environment) alongside a token file is an error.
+func (c *Config) resolveToken() error {
+	if c.Linkding.TokenFile == "" {
+		return nil
+	}
+
+	if c.Linkding.Token != "" {
+		return fmt.Errorf("both an inline Linkding token and linkding.token_file are set; use only one")
+	}
+
+	data, err := os.ReadFile(c.Linkding.TokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to read linkding token file: %w", err)
+	}
+
+	c.Linkding.Token = strings.TrimSpace(string(data))
+	if c.Linkding.Token == "" {
+		return fmt.Errorf("linkding token file %s is empty", c.Linkding.TokenFile)
+	}
+
+	logrus.WithField("token_file", c.Linkding.TokenFile).Debug("Read Linkding token from file")
+	return nil
+}
+
+// FeedFetchMaxRedirects returns the redirect limit for candidate feed fetches,
+// falling back to the global HTTP setting when unset
+func (c *Config) FeedFetchMaxRedirects() int {
+	if c.FeedFetch.MaxRedirects > 0 {
+		return c.FeedFetch.MaxRedirects
+	}
+	return c.HTTP.MaxRedirects
+}
+
+// HostHeadersByHost returns the per-host headers keyed by hostname, merging
+// entries that list the same host
+func (c *Config) HostHeadersByHost() map[string]map[string]string {
+	if len(c.HTTP.HostHeaders) == 0 {
+		return nil
+	}
+
+	byHost := make(map[string]map[string]string, len(c.HTTP.HostHeaders))
+	for _, entry := range c.HTTP.HostHeaders {
+		host := strings.ToLower(strings.TrimSpace(entry.Host))
+		if byHost[host] == nil {
+			byHost[host] = make(map[string]string, len(entry.Headers))
+		}
+		for name, value := range entry.Headers {
+			byHost[host][name] = value
+		}
+	}
+	return byHost
+}
+
+// BasicAuthByHost returns the Basic Auth credentials keyed by hostname. When a
+// host is listed more than once, the last entry wins.
+func (c *Config) BasicAuthByHost() map[string]HostBasicAuth {
+	if len(c.HTTP.BasicAuth) == 0 {
+		return nil
+	}
+
+	byHost := make(map[string]HostBasicAuth, len(c.HTTP.BasicAuth))
+	for _, entry := range c.HTTP.BasicAuth {
+		byHost[strings.ToLower(strings.TrimSpace(entry.Host))] = entry
+	}
+	return byHost
+}
+
+// RotatingUserAgents returns the user agents to rotate through, or nil when
+// rotation is off and the single http.user_agent should be used
+func (c *Config) RotatingUserAgents() []string {
+	if !c.HTTP.UserAgentRotate {
+		return nil
+	}
+	return c.HTTP.UserAgents
+}
+
+// TLSConfig builds the TLS configuration for outbound HTTP requests, or returns
+// nil when the defaults (system roots, full verification) should be used
+func (c *Config) TLSConfig() (*tls.Config, error) {
+	if !c.HTTP.InsecureSkipVerify && c.HTTP.CACertFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.HTTP.InsecureSkipVerify, //nolint:gosec // explicit opt-in for self-hosted instances
+	}
+
+	if c.HTTP.CACertFile != "" {
+		pem, err := os.ReadFile(c.HTTP.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate file: %w", err)
+		}
+
+		// Add the custom CA on top of the system roots
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid PEM certificates found in %s", c.HTTP.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.HTTP.InsecureSkipVerify {
+		logrus.Warn("TLS certificate verification is disabled; connections are vulnerable to interception")
+	}
+
+	return tlsConfig, nil
+}
+
+// Validate checks that required configuration is present. It also resolves
+// the Linkding token from linkding.token_file when that is the token source.
 func (c *Config) Validate() error {
+	if err := c.resolveToken(); err != nil {
+		return err
+	}
+
 	if c.Linkding.Token == "" {
-		return fmt.Errorf("linkding token is required (set via --linkding-token flag or linkding.token in config)")
+		return fmt.Errorf("linkding token is required (set via --linkding-token, --linkding-token-file, linkding.token or linkding.token_file in config, or LINKDING_TO_OPML_LINKDING_TOKEN)")
 	}
 
 	if c.Linkding.URL == "" {
 		return fmt.Errorf("linkding URL is required (set via --linkding-url flag or linkding.url in config)")
 	}
 
+	if c.HTTP.Proxy != "" {
+		proxyURL, err := url.Parse(c.HTTP.Proxy)
+		if err != nil {
+			return fmt.Errorf("invalid http.proxy URL: %w", err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("unsupported http.proxy scheme %q (use http, https, socks5 or socks5h)", proxyURL.Scheme)
+		}
+	}
+
+	if _, err := c.SinceCutoff(time.Now()); err != nil {
+		return err
+	}
+
+	switch c.Cache.Backend {
+	case "", "file", "sqlite":
+	default:
+		return fmt.Errorf("invalid cache.backend %q (use file or sqlite)", c.Cache.Backend)
+	}
+
+	switch c.Cache.Format {
+	case "", "gob", "json":
+	default:
+		return fmt.Errorf("invalid cache.format %q (use gob or json)", c.Cache.Format)
+	}
+
+	if c.Cache.Compress && c.Cache.Backend == "sqlite" {
+		return fmt.Errorf("cache.compress only applies to the file cache backend, not sqlite")
+	}
+
+	if c.Cache.MinTTL < time.Second {
+		return fmt.Errorf("cache.min_ttl must be at least 1s")
+	}
+	if c.Cache.MaxTTL < 0 || (c.Cache.MaxTTL > 0 && c.Cache.MaxTTL < c.Cache.MinTTL) {
+		return fmt.Errorf("cache.max_ttl must be 0 (the cache max age) or at least cache.min_ttl")
+	}
+
+	switch c.OPMLVersion {
+	case "1.0", "2.0":
+	default:
+		return fmt.Errorf("invalid OPML version %q (use 1.0 or 2.0)", c.OPMLVersion)
+	}
+
+	switch c.OPMLTextSource {
+	case "", "title", "domain", "url":
+	default:
+		return fmt.Errorf("invalid OPML text source %q (use title, domain or url)", c.OPMLTextSource)
+	}
+
+	switch c.Sort {
+	case "", "none", "title", "url":
+	default:
+		return fmt.Errorf("invalid sort mode %q (use title, url or none)", c.Sort)
+	}
+
+	if c.Deterministic && c.Sort == "title" {
+		return fmt.Errorf("--deterministic sorts outlines by URL and cannot be used with --sort title")
+	}
+
+	for i, entry := range c.HTTP.HostHeaders {
+		if strings.TrimSpace(entry.Host) == "" {
+			return fmt.Errorf("http.host_headers entry %d is missing a host", i+1)
+		}
+	}
+	for i, entry := range c.HTTP.BasicAuth {
+		if strings.TrimSpace(entry.Host) == "" {
+			return fmt.Errorf("http.basic_auth entry %d is missing a host", i+1)
+		}
+		if entry.Username == "" {
+			return fmt.Errorf("http.basic_auth entry %d (%s) is missing a username", i+1, entry.Host)
+		}
+	}
+
+	if c.SkipList.AddOnFail && c.SkipList.FilePath == "" {
+		return fmt.Errorf("--add-skip-on-fail requires a skip list file (set via --skip-list or skip_list.file_path in config)")
+	}
+
+	if c.Append && c.WritesToStdout() {
+		return fmt.Errorf("--append cannot be used when writing OPML to stdout")
+	}
+
+	if c.Merge && c.WritesToStdout() {
+		return fmt.Errorf("--merge cannot be used when writing OPML to stdout")
+	}
+
+	if c.Append && c.Merge {
+		return fmt.Errorf("--append and --merge cannot be used together")
+	}
+
+	if c.Stream {
+		if option := c.streamConflict(); option != "" {
+			return fmt.Errorf("--stream cannot be used with %s, which needs every feed before writing", option)
+		}
+	}
+
+	if c.HTTP.MaxPerHost < 0 {
+		return fmt.Errorf("http.max_per_host cannot be negative")
+	}
+
+	if c.HTTP.DialTimeout < 0 || c.HTTP.TLSHandshakeTimeout < 0 || c.HTTP.ResponseHeaderTimeout < 0 {
+		return fmt.Errorf("http dial, TLS handshake and response header timeouts cannot be negative")
+	}
+
+	if c.HTTP.UserAgentRotate && len(c.HTTP.UserAgents) == 0 {
+		return fmt.Errorf("--user-agent-rotate requires a list of user agents in http.user_agents")
+	}
+
+	if c.Deadline < 0 {
+		return fmt.Errorf("deadline cannot be negative")
+	}
+
+	if c.Notify.Webhook != "" {
+		webhookURL, err := url.Parse(c.Notify.Webhook)
+		if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" {
+			return fmt.Errorf("invalid notify.webhook URL %q (use an http or https URL)", c.Notify.Webhook)
+		}
+	}
+
+	switch c.Notify.On {
+	case "always", "failure":
+	default:
+		return fmt.Errorf("invalid notify.on mode %q (use always or failure)", c.Notify.On)
+	}
+
+	if c.MinFeeds < 0 {
+		return fmt.Errorf("min_feeds cannot be negative")
+	}
+
+	if c.MaxShrinkPercent < 0 || c.MaxShrinkPercent > 100 {
+		return fmt.Errorf("max_shrink_percent must be between 0 and 100")
+	}
+
+	if c.MaxFeedsPerDomain < 0 {
+		return fmt.Errorf("max_feeds_per_domain cannot be negative")
+	}
+
+	if c.HTTP.RetryAttempts < 0 {
+		return fmt.Errorf("http.retry_attempts cannot be negative")
+	}
+
+	if c.HTTP.RetryBaseBackoff < 0 || c.HTTP.RetryMaxBackoff < 0 || c.HTTP.RetryAfterMax < 0 {
+		return fmt.Errorf("http retry backoff durations cannot be negative")
+	}
+
+	if c.FeedFetch.RetryAttempts < 0 {
+		return fmt.Errorf("feed_fetch.retry_attempts cannot be negative")
+	}
+
+	if c.FeedFetch.RetryBaseBackoff < 0 || c.FeedFetch.RetryMaxBackoff < 0 || c.FeedFetch.RetryAfterMax < 0 {
+		return fmt.Errorf("feed_fetch retry backoff durations cannot be negative")
+	}
+
+	switch c.Discovery.CommonPathsMode {
+	case "", "append", "replace":
+	default:
+		return fmt.Errorf("invalid discovery.common_paths_mode %q (use append or replace)", c.Discovery.CommonPathsMode)
+	}
+
+	if c.Discovery.CommonPathsMode == "replace" && len(c.Discovery.CommonPaths) == 0 {
+		logrus.Warn("discovery.common_paths_mode is replace but no common_paths are set; using the built-in defaults")
+	}
+
+	for _, source := range c.Discovery.TitleFallback {
+		switch source {
+		case "bookmark", "page", "domain":
+		default:
+			return fmt.Errorf("invalid discovery.title_fallback source %q (use bookmark, page or domain)", source)
+		}
+	}
+
 	return nil
 }
 
-// SetupLogging configures logrus based on the logging settings
-func (c *Config) SetupLogging() {
-	logrus.SetOutput(os.Stdout)
+// SinceCutoff returns the time before which bookmarks are skipped, relative to
+// now, or the zero time when no --since filter is set. The value may be an
+// RFC3339 timestamp or a duration; durations also accept a "d" suffix for days.
+func (c *Config) SinceCutoff(now time.Time) (time.Time, error) {
+	if c.Since == "" {
+		return time.Time{}, nil
+	}
+
+	if cutoff, err := time.Parse(time.RFC3339, c.Since); err == nil {
+		return cutoff, nil
+	}
+
+	var duration time.Duration
+	if days, ok := strings.CutSuffix(c.Since, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid since value %q (use a duration like 72h or 7d, or an RFC3339 timestamp)", c.Since)
+		}
+		duration = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(c.Since)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid since value %q (use a duration like 72h or 7d, or an RFC3339 timestamp)", c.Since)
+		}
+		duration = d
+	}
+
+	if duration < 0 {
+		return time.Time{}, fmt.Errorf("since duration cannot be negative")
+	}
+
+	return now.Add(-duration), nil
+}
+
+// streamConflict returns the first enabled option that needs the whole
+// outline list in memory, which --stream can't provide, or "" if there is none
+func (c *Config) streamConflict() string {
+	switch {
+	case c.Append:
+		return "--append"
+	case c.Merge:
+		return "--merge"
+	case c.Sort != "" && c.Sort != "none":
+		return "--sort"
+	case c.Deterministic:
+		return "--deterministic"
+	case c.OPMLVersion == "1.0":
+		return "--opml-version 1.0"
+	case c.IncludeUnreachable:
+		return "--include-unreachable"
+	case c.IncludeCategories:
+		return "--include-categories"
+	case c.MaxFeedsPerDomain > 0:
+		return "--max-feeds-per-domain"
+	case c.DedupeAgainstExisting:
+		return "--dedupe-against-existing"
+	case c.DedupeAgainstOPML != "":
+		return "--dedupe-against-opml"
+	case c.MinFeeds > 0:
+		return "--min-feeds"
+	case c.MaxShrinkPercent > 0:
+		return "--max-shrink-percent"
+	}
+	return ""
+}
+
+// WritesToStdout returns true if the OPML output goes to stdout ("-")
+func (c *Config) WritesToStdout() bool {
+	return c.Output == "-"
+}
+
+// SetupLogging configures logrus based on the logging settings and returns a
+// cleanup function that closes the log file, if one was opened
+func (c *Config) SetupLogging() func() {
+	cleanup := func() {}
+
+	// Keep stdout clean for piped OPML output
+	if c.WritesToStdout() {
+		logrus.SetOutput(os.Stderr)
+	} else {
+		logrus.SetOutput(os.Stdout)
+	}
 	logrus.SetFormatter(&logrus.TextFormatter{
 		FullTimestamp: true,
 	})
 
+	if c.LogFile != "" {
+		file, err := os.OpenFile(c.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"log_file": c.LogFile,
+				"error":    err,
+			}).Warn("Failed to open log file, logging to console instead")
+		} else {
+			logrus.SetOutput(file)
+			// Plain output without terminal colors for the persistent log
+			logrus.SetFormatter(&logrus.TextFormatter{
+				FullTimestamp: true,
+				DisableColors: true,
+			})
+			cleanup = func() {
+				logrus.SetOutput(os.Stderr)
+				file.Close()
+			}
+		}
+	}
+
 	if c.Debug {
 		logrus.SetLevel(logrus.DebugLevel)
 	} else if c.Verbose {
@@ -119,4 +786,6 @@ func (c *Config) SetupLogging() {
 	} else {
 		logrus.SetLevel(logrus.WarnLevel)
 	}
+
+	return cleanup
 }
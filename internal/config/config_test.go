@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// TestExampleConfigUsesDefaults checks that every value set in the example
+// config is the built-in default, apart from the Linkding credentials, so
+// copying it doesn't change behavior
+func TestExampleConfigUsesDefaults(t *testing.T) {
+	file, err := os.Open("../../linkding-to-opml.yaml.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	v := viper.New()
+	setDefaults(v)
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(file); err != nil {
+		t.Fatalf("ReadConfig() error = %v", err)
+	}
+	var example Config
+	if err := v.Unmarshal(&example); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	defaults, err := DefaultConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults.Linkding.URL = example.Linkding.URL
+	defaults.Linkding.Token = example.Linkding.Token
+
+	got := reflect.ValueOf(example)
+	want := reflect.ValueOf(*defaults)
+	for i := range got.NumField() {
+		if !equalOrEmpty(got.Field(i), want.Field(i)) {
+			t.Errorf("example %s = %+v, want default %+v", got.Type().Field(i).Name, got.Field(i), want.Field(i))
+		}
+	}
+}
+
+// equalOrEmpty compares config values, treating nil and empty slices and maps
+// as equal since YAML's [] and {} decode to empty rather than nil values
+func equalOrEmpty(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Slice, reflect.Map:
+		if a.Len() == 0 && b.Len() == 0 {
+			return true
+		}
+	case reflect.Struct:
+		for i := range a.NumField() {
+			if !equalOrEmpty(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
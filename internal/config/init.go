@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"text/template"
+
+	"github.com/spf13/viper"
+)
+
+// DefaultConfig returns the configuration used when no file, flag or
+// environment variable overrides anything
+func DefaultConfig() (*Config, error) {
+	v := viper.New()
+	setDefaults(v)
+
+	var config Config
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, fmt.Errorf("error unmarshalling default config: %w", err)
+	}
+	return &config, nil
+}
+
+// initialConfigTemplate is the starter config written by the init command. It
+// covers the settings most setups touch; linkding-to-opml.yaml.example
+// documents the rest.
+var initialConfigTemplate = template.Must(template.New("config").Parse(`# linkding-to-opml configuration
+# Generated by "linkding-to-opml init". Every value below except the Linkding
+# token and URL is the built-in default; uncomment or edit what you need.
+# See linkding-to-opml.yaml.example for all available options.
+
+# Linkding API configuration
+linkding:
+  # Your Linkding API token (required). Find it under Settings > Integrations.
+  # Alternatively set token_file, or the LINKDING_TO_OPML_LINKDING_TOKEN
+  # environment variable, to keep it out of this file.
+  token: "your-api-token-here"
+  # token_file: "/run/secrets/linkding-token"
+
+  # Your Linkding server URL (required)
+  url: "https://your-linkding-instance.com"
+
+  # API timeout
+  timeout: "{{.Linkding.Timeout}}"
+
+# OPML output file path, or - for stdout
+output: "{{.Output}}"
+
+# Only export bookmarks with ALL of these tags (empty = all bookmarks)
+tags: []
+
+# Number of bookmarks probed for feeds at once
+concurrency: {{.Concurrency}}
+
+# Cache of feed discovery results
+cache:
+  # Cache file path
+  file_path: "{{.Cache.FilePath}}"
+
+  # How long results are reused before being rediscovered, in hours
+  max_age: {{.Cache.MaxAge}}
+
+  # How long pages that answered 401/403 are cached, in hours
+  auth_required_max_age: {{.Cache.AuthRequiredMaxAge}}
+
+# HTTP client configuration for feed discovery
+http:
+  # Timeout for fetching a page or feed, including its body
+  timeout: "{{.HTTP.Timeout}}"
+
+  # User-Agent sent with every request
+  user_agent: "{{.HTTP.UserAgent}}"
+
+  # Maximum number of redirects to follow
+  max_redirects: {{.HTTP.MaxRedirects}}
+
+  # Largest page or feed body read, in bytes
+  max_body_bytes: {{.HTTP.MaxBodyBytes}}
+
+  # Per-phase timeouts
+  dial_timeout: "{{.HTTP.DialTimeout}}"
+  tls_handshake_timeout: "{{.HTTP.TLSHandshakeTimeout}}"
+  response_header_timeout: "{{.HTTP.ResponseHeaderTimeout}}"
+
+# OPML version to write: 1.0 or 2.0
+opml_version: "{{.OPMLVersion}}"
+
+# Order of feeds in the OPML: title, url or none
+sort: "{{.Sort}}"
+`))
+
+// WriteInitialConfig writes a commented starter config, filled in with the
+// defaults and placeholders for the Linkding token and URL
+func WriteInitialConfig(w io.Writer) error {
+	defaults, err := DefaultConfig()
+	if err != nil {
+		return err
+	}
+	return initialConfigTemplate.Execute(w, defaults)
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"crypto/tls"
+
+	"github.com/lmorchard/linkding-to-opml/internal/feeds"
+)
+
+// ProcessingConfig maps the configuration onto the feed processing settings,
+// using tlsConfig (see TLSConfig) for every discovery request
+func (c *Config) ProcessingConfig(tlsConfig *tls.Config) feeds.ProcessingConfig {
+	return feeds.ProcessingConfig{
+		Concurrency: c.Concurrency,
+		MaxAge:      c.Cache.MaxAge,
+		AuthMaxAge:  c.Cache.AuthRequiredMaxAge,
+		MinTTL:      c.Cache.MinTTL,
+		MaxTTL:      c.Cache.MaxTTL,
+		NoCache:     c.Cache.Disabled,
+		VerifyCache: c.Cache.Verify,
+		UserAgent:   c.HTTP.UserAgent,
+		HTTPConfig: feeds.HTTPConfig{
+			Timeout:      c.HTTP.Timeout,
+			UserAgent:    c.HTTP.UserAgent,
+			MaxRedirects: c.HTTP.MaxRedirects,
+			MaxBodyBytes: c.HTTP.MaxBodyBytes,
+			MaxPerHost:   c.HTTP.MaxPerHost,
+			TLSConfig:    tlsConfig,
+			Proxy:        c.HTTP.Proxy,
+			Headers:      c.HTTP.Headers,
+			HostHeaders:  c.HostHeadersByHost(),
+			BasicAuth:    c.basicAuthCredentials(),
+
+			DialTimeout:           c.HTTP.DialTimeout,
+			TLSHandshakeTimeout:   c.HTTP.TLSHandshakeTimeout,
+			ResponseHeaderTimeout: c.HTTP.ResponseHeaderTimeout,
+		},
+		FeedHTTPConfig: feeds.HTTPConfig{
+			Timeout:      c.HTTP.Timeout,
+			UserAgent:    c.HTTP.UserAgent,
+			MaxRedirects: c.FeedFetchMaxRedirects(),
+			MaxBodyBytes: c.HTTP.MaxBodyBytes,
+			MaxPerHost:   c.HTTP.MaxPerHost,
+			TLSConfig:    tlsConfig,
+			Proxy:        c.HTTP.Proxy,
+			Headers:      c.HTTP.Headers,
+			HostHeaders:  c.HostHeadersByHost(),
+			BasicAuth:    c.basicAuthCredentials(),
+
+			DialTimeout:           c.HTTP.DialTimeout,
+			TLSHandshakeTimeout:   c.HTTP.TLSHandshakeTimeout,
+			ResponseHeaderTimeout: c.HTTP.ResponseHeaderTimeout,
+		},
+		PageRetries: c.HTTP.RetryAttempts,
+		PageBackoff: feeds.RetryBackoff{
+			Base: c.HTTP.RetryBaseBackoff,
+			Max:  c.HTTP.RetryMaxBackoff,
+
+			RetryAfterMax: c.HTTP.RetryAfterMax,
+		},
+		FeedRetries:    c.FeedFetch.RetryAttempts,
+		Verbose:        c.Verbose,
+		SaveFailedHTML: c.SaveFailedHTML,
+		DebugOutputDir: c.DebugOutputDir,
+		FeedBackoff: feeds.RetryBackoff{
+			Base: c.FeedFetch.RetryBaseBackoff,
+			Max:  c.FeedFetch.RetryMaxBackoff,
+
+			RetryAfterMax: c.FeedFetch.RetryAfterMax,
+		},
+
+		CommonFeedPaths: feeds.ResolveCommonFeedPaths(c.Discovery.CommonPaths, c.Discovery.CommonPathsMode),
+		NoCommonPaths:   c.Discovery.CommonPathsDisabled,
+		TitleFallbacks:  c.Discovery.TitleFallback,
+		AddSkipOnFail:   c.SkipList.AddOnFail,
+		UserAgents:      c.RotatingUserAgents(),
+
+		AdaptiveConcurrency: c.AdaptiveConcurrency,
+		ValidateFeeds:       c.ValidateFeeds,
+		FailFast:            c.FailFast,
+		RetryBlocked:        c.HTTP.RetryBlocked,
+	}
+}
+
+// basicAuthCredentials maps the configured Basic Auth entries onto the
+// credentials used by the feed discovery HTTP clients
+func (c *Config) basicAuthCredentials() map[string]feeds.BasicAuthCredentials {
+	byHost := c.BasicAuthByHost()
+	if byHost == nil {
+		return nil
+	}
+
+	credentials := make(map[string]feeds.BasicAuthCredentials, len(byHost))
+	for host, entry := range byHost {
+		credentials[host] = feeds.BasicAuthCredentials{
+			Username:  entry.Username,
+			Password:  entry.Password,
+			AllowHTTP: entry.AllowHTTP,
+		}
+	}
+	return credentials
+}
@@ -0,0 +1,80 @@
+package feeds
+
+import (
+	"strings"
+	"time"
+)
+
+// StaleFeedAge is how long a feed can go without a new item before it is
+// reported as stale
+const StaleFeedAge = 2 * 365 * 24 * time.Hour
+
+// feedDateLayouts are the timestamp formats seen in RSS pubDate, Atom updated
+// and JSON Feed date fields, tried in order
+var feedDateLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseFeedDate parses a feed timestamp, returning false if no known layout matches
+func parseFeedDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	for _, layout := range feedDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// latestFeedDate returns the most recent parseable timestamp among the values
+func latestFeedDate(values ...string) time.Time {
+	var latest time.Time
+	for _, value := range values {
+		if t, ok := parseFeedDate(value); ok && t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// latestItemDate returns the most recent item timestamp, falling back to
+// feed-level dates (lastBuildDate, updated, etc.) when items carry none. Item
+// dates win because many generators bump the feed date on every rebuild.
+func latestItemDate(items []feedItem, feedDates ...string) time.Time {
+	var dates []string
+	for _, item := range items {
+		dates = append(dates, item.PubDate, item.DCDate, item.Updated, item.Published)
+	}
+	if latest := latestFeedDate(dates...); !latest.IsZero() {
+		return latest
+	}
+	return latestFeedDate(feedDates...)
+}
+
+// IsStale returns true if a discovered feed has no items or hasn't published
+// anything within StaleFeedAge of now. Feeds without parseable dates are only
+// judged by their item count.
+func (r *FeedDiscoveryResult) IsStale(now time.Time) bool {
+	if !r.IsSuccessful() || !r.ActivityKnown {
+		return false
+	}
+	if r.ItemCount == 0 {
+		return true
+	}
+	return !r.LastUpdated.IsZero() && now.Sub(r.LastUpdated) > StaleFeedAge
+}
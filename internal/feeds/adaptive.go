@@ -0,0 +1,127 @@
+package feeds
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// adaptiveStartConcurrency is the initial number of concurrent discoveries in adaptive mode
+	adaptiveStartConcurrency = 2
+
+	// adaptiveDecreaseCooldown keeps a burst of failures from collapsing the limit repeatedly
+	adaptiveDecreaseCooldown = 2 * time.Second
+
+	// defaultSlowThreshold is used when no HTTP timeout is configured
+	defaultSlowThreshold = 10 * time.Second
+)
+
+// adaptiveLimiter bounds the number of in-flight feed discoveries and tunes
+// that bound with additive-increase/multiplicative-decrease: the limit grows
+// by one after a full window of healthy discoveries and halves when a
+// discovery is slow or fails with a transient error (timeout, 5xx, 429).
+type adaptiveLimiter struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	limit  int
+	active int
+	min    int
+	max    int
+	peak   int
+
+	successes     int
+	lastDecrease  time.Time
+	slowThreshold time.Duration
+}
+
+// newAdaptiveLimiter creates a limiter that ranges between 1 and maxConcurrency.
+// Discoveries slower than slowThreshold count as congestion.
+func newAdaptiveLimiter(maxConcurrency int, slowThreshold time.Duration) *adaptiveLimiter {
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+	if slowThreshold <= 0 {
+		slowThreshold = defaultSlowThreshold
+	}
+
+	start := min(adaptiveStartConcurrency, maxConcurrency)
+	l := &adaptiveLimiter{
+		limit:         start,
+		min:           1,
+		max:           maxConcurrency,
+		peak:          start,
+		slowThreshold: slowThreshold,
+	}
+	l.cond = sync.NewCond(&l.mu)
+
+	return l
+}
+
+// Acquire blocks until a discovery slot is available. A nil limiter never blocks.
+func (l *adaptiveLimiter) Acquire() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.active >= l.limit {
+		l.cond.Wait()
+	}
+	l.active++
+}
+
+// Release frees a slot and adjusts the limit based on how the discovery went
+func (l *adaptiveLimiter) Release(latency time.Duration, congested bool) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active--
+
+	previous := l.limit
+	if congested || latency > l.slowThreshold {
+		l.successes = 0
+		if time.Since(l.lastDecrease) > adaptiveDecreaseCooldown {
+			l.limit = max(l.min, l.limit/2)
+			l.lastDecrease = time.Now()
+		}
+	} else {
+		l.successes++
+		if l.successes >= l.limit && l.limit < l.max {
+			l.limit++
+			l.successes = 0
+		}
+	}
+
+	if l.limit != previous {
+		l.peak = max(l.peak, l.limit)
+		logrus.WithFields(logrus.Fields{
+			"previous":  previous,
+			"limit":     l.limit,
+			"latency":   latency,
+			"congested": congested,
+		}).Debug("Adjusted adaptive concurrency")
+	}
+
+	l.cond.Broadcast()
+}
+
+// Limits returns the current and peak concurrency limits
+func (l *adaptiveLimiter) Limits() (current, peak int) {
+	if l == nil {
+		return 0, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.limit, l.peak
+}
@@ -1,9 +1,15 @@
 package feeds
 
 import (
+	"context"
 	"crypto/md5"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"mime"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -13,31 +19,285 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
 )
 
+// ErrAuthRequired indicates the bookmark page responded with 401/403 and can't be
+// processed without credentials
+var ErrAuthRequired = errors.New("authentication required")
+
+// ErrPageGone indicates the bookmark page no longer exists: it answered 404/410
+// or its host name doesn't resolve
+var ErrPageGone = errors.New("page gone")
+
+// ErrNoFeedLinks indicates the bookmark page loaded but advertises no feed,
+// and no common feed path turned one up
+var ErrNoFeedLinks = errors.New("no feed links found in page")
+
+// ErrSkipped indicates the bookmark is on the user's skip list and was not probed
+var ErrSkipped = errors.New("listed in skip list")
+
+// ErrUnsupportedScheme indicates the bookmark URL isn't http or https (ftp:,
+// file:, javascript:, magnet: and so on) and can't be probed for a feed
+var ErrUnsupportedScheme = errors.New("unsupported URL scheme")
+
 // FeedDiscoveryResult represents the result of attempting to discover a feed from a URL
 type FeedDiscoveryResult struct {
-	URL       string `json:"url"`        // Original bookmark URL
-	FeedURL   string `json:"feed_url"`   // Discovered feed URL
-	FeedTitle string `json:"feed_title"` // Feed title from feed metadata
-	Error     error  `json:"error"`      // Error if discovery failed
+	URL        string `json:"url"`         // Original bookmark URL
+	FeedURL    string `json:"feed_url"`    // Discovered feed URL (the feed's self URL when declared)
+	FetchedURL string `json:"fetched_url"` // Feed URL that was actually fetched
+	FeedTitle  string `json:"feed_title"`  // Feed title from feed metadata
+	Language   string `json:"language"`    // Feed language (RSS <language> or Atom xml:lang), if declared
+	FeedType   string `json:"feed_type"`   // Feed format: rss, atom, rdf or json
+	IconURL    string `json:"icon_url"`    // Absolute URL of the feed's icon or logo, if declared
+	HubURL     string `json:"hub_url"`     // WebSub hub the feed declares via rel="hub", if any
+	Error      error  `json:"error"`       // Error if discovery failed
+
+	// How long the result may be cached, from the page's (or else the feed's)
+	// Cache-Control max-age; zero uses the configured cache max age.
+	// CacheNoStore is set instead when the response forbids reuse, and the
+	// result is cached for the shortest time allowed.
+	CacheTTL     time.Duration `json:"cache_ttl,omitempty"`
+	CacheNoStore bool          `json:"cache_no_store,omitempty"`
+
+	Warnings []FeedWarning `json:"warnings,omitempty"` // Feed problems found when validation is enabled
+	Tags     []string      `json:"tags,omitempty"`     // Tags of the bookmark the feed was discovered from
+
+	// Feed activity, used to flag feeds that have gone quiet
+	ItemCount     int       `json:"item_count"`     // Number of items/entries in the feed document
+	LastUpdated   time.Time `json:"last_updated"`   // Most recent item or feed timestamp, if any
+	ActivityKnown bool      `json:"activity_known"` // False for results cached before activity was recorded
+
+	// Where the result came from, set by the workers and counted by
+	// ProcessBookmarks' collector so stats are only written from one goroutine
+	cacheHit       bool // Served from the cache
+	newDiscovery   bool // Discovered by fetching the page
+	deadCachedFeed bool // A cached feed failed verification before rediscovery
 }
 
-// RSS represents a simplified RSS feed structure for title extraction
+// RSS represents a simplified RSS feed structure for metadata extraction
 type RSS struct {
 	XMLName xml.Name `xml:"rss"`
 	Channel Channel  `xml:"channel"`
 }
 
-// Atom represents a simplified Atom feed structure for title extraction
+// feedItem holds the timestamps of a single RSS item or Atom entry
+type feedItem struct {
+	PubDate   string `xml:"pubDate"`
+	DCDate    string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Updated   string `xml:"updated"`
+	Published string `xml:"published"`
+}
+
+// Atom represents a simplified Atom feed structure for metadata extraction
 type Atom struct {
-	XMLName xml.Name `xml:"feed"`
-	Title   string   `xml:"title"`
+	XMLName xml.Name   `xml:"feed"`
+	Lang    string     `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Title   string     `xml:"title"`
+	Updated string     `xml:"updated"`
+	Icon    string     `xml:"icon"`
+	Logo    string     `xml:"logo"`
+	Links   []AtomLink `xml:"link"`
+	Entries []feedItem `xml:"entry"`
+}
+
+// RDF represents a simplified RSS 1.0 (RDF) feed structure for metadata extraction
+type RDF struct {
+	XMLName xml.Name `xml:"RDF"`
+	Channel struct {
+		Title    string        `xml:"title"`
+		Language string        `xml:"http://purl.org/dc/elements/1.1/ language"`
+		DCDate   string        `xml:"http://purl.org/dc/elements/1.1/ date"`
+		Links    []ChannelLink `xml:"link"`
+	} `xml:"channel"`
+	Image RSSImage   `xml:"image"`
+	Items []feedItem `xml:"item"`
+}
+
+// JSONFeed represents the top-level fields of a JSON Feed used for metadata extraction
+type JSONFeed struct {
+	Version     string `json:"version"`
+	Title       string `json:"title"`
+	HomePageURL string `json:"home_page_url"`
+	FeedURL     string `json:"feed_url"`
+	Language    string `json:"language"`
+	Icon        string `json:"icon"`
+	Favicon     string `json:"favicon"`
+	Items       []struct {
+		DatePublished string `json:"date_published"`
+		DateModified  string `json:"date_modified"`
+	} `json:"items"`
+	Hubs []struct {
+		Type string `json:"type"`
+		URL  string `json:"url"`
+	} `json:"hubs"`
 }
 
 // Channel represents an RSS channel
 type Channel struct {
-	Title string `xml:"title"`
+	Title         string        `xml:"title"`
+	Language      string        `xml:"language"`
+	LastBuildDate string        `xml:"lastBuildDate"`
+	PubDate       string        `xml:"pubDate"`
+	Links         []ChannelLink `xml:"link"`
+	Image         RSSImage      `xml:"image"`
+	Items         []feedItem    `xml:"item"`
+}
+
+// ChannelLink is a <link> element of an RSS or RDF channel, whatever its
+// namespace: either the plain RSS link, with the website URL as its text, or
+// an Atom-style link such as atom:link rel="self" carrying rel and href
+type ChannelLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Text string `xml:",chardata"`
+}
+
+// channelAtomLinks returns the Atom-style links among a channel's links
+func channelAtomLinks(links []ChannelLink) []AtomLink {
+	var atomLinks []AtomLink
+	for _, link := range links {
+		if strings.TrimSpace(link.Href) != "" {
+			atomLinks = append(atomLinks, AtomLink{Rel: link.Rel, Href: link.Href})
+		}
+	}
+	return atomLinks
+}
+
+// channelSiteURL returns a channel's website URL: the plain RSS link, else an
+// Atom-style link other than self or hub, else the self link
+func channelSiteURL(links []ChannelLink) string {
+	for _, link := range links {
+		if strings.TrimSpace(link.Href) == "" && strings.TrimSpace(link.Text) != "" {
+			return strings.TrimSpace(link.Text)
+		}
+	}
+
+	atomLinks := channelAtomLinks(links)
+	for _, link := range atomLinks {
+		switch strings.ToLower(strings.TrimSpace(link.Rel)) {
+		case "self", "hub":
+		default:
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	return findLinkHref(atomLinks, "self")
+}
+
+// RSSImage represents the <image> element of an RSS channel
+type RSSImage struct {
+	URL string `xml:"url"`
+}
+
+// AtomLink represents an Atom <link> element, used natively in Atom feeds and
+// via the atom namespace in RSS channels
+type AtomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// feedMetadata holds the details extracted from a parsed feed document
+type feedMetadata struct {
+	Title    string
+	SelfURL  string // URL the feed declares for itself via rel="self", if any
+	Language string
+	FeedType string
+	IconURL  string // Icon or logo URL as declared, possibly relative
+	HubURL   string // WebSub hub declared via rel="hub", possibly relative
+	SiteURL  string // Site link the icon URL is relative to, if declared
+
+	ItemCount   int
+	LastUpdated time.Time
+}
+
+// Feed formats recognized during discovery, used as the OPML outline type
+const (
+	FeedTypeRSS  = "rss"
+	FeedTypeAtom = "atom"
+	FeedTypeRDF  = "rdf"
+	FeedTypeJSON = "json"
+)
+
+// DiscoveryOptions controls how a single feed discovery is performed
+type DiscoveryOptions struct {
+	HTTPClient        *HTTPClient  // Client used to fetch bookmark pages
+	FeedClient        *HTTPClient  // Client used to fetch candidate feeds (defaults to HTTPClient)
+	PageRetryAttempts int          // Number of retries for retryable bookmark page fetch errors
+	PageRetryBackoff  RetryBackoff // Delay between bookmark page fetch retries
+	FeedRetryAttempts int          // Number of retries for retryable candidate feed fetch errors
+	FeedRetryBackoff  RetryBackoff // Delay between candidate feed fetch retries
+	UserAgent         string
+	SaveFailedHTML    bool
+	DebugOutputDir    string
+	CommonPaths       []string // Paths probed when a page has no feed links (defaults to DefaultCommonFeedPaths)
+	ValidateFeed      bool     // Check the discovered feed for common problems and record warnings
+	RetryBlocked      bool     // Retry once with BrowserUserAgent when the page looks soft-blocked
+	NoCommonPaths     bool     // Never probe common paths, even when the page has no feed links
+
+	// TitleFallbacks are the sources, in order, of a title for a feed that
+	// has none (TitleFallbackBookmark, TitleFallbackPage, TitleFallbackDomain).
+	// Without them an untitled feed is rejected.
+	TitleFallbacks []string
+	BookmarkTitle  string // Title of the bookmark, for TitleFallbackBookmark
+}
+
+// BrowserUserAgent is a current desktop browser's user agent, used to retry
+// pages that serve an error page to unfamiliar clients
+const BrowserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36"
+
+// DefaultCommonFeedPaths are the built-in locations probed as a last resort
+var DefaultCommonFeedPaths = []string{
+	"/feed",
+	"/feed.xml",
+	"/rss",
+	"/rss.xml",
+	"/atom.xml",
+	"/feeds/all.atom.xml",
+	"/index.xml",
+	"/.rss",
+}
+
+// Modes for combining user-configured common paths with the built-in defaults
+const (
+	CommonPathsAppend  = "append"
+	CommonPathsReplace = "replace"
+)
+
+// ResolveCommonFeedPaths combines configured common feed paths with the
+// built-in defaults. In replace mode the configured paths are used on their
+// own; otherwise they are appended to the defaults, skipping duplicates.
+func ResolveCommonFeedPaths(paths []string, mode string) []string {
+	if len(paths) == 0 {
+		return DefaultCommonFeedPaths
+	}
+
+	var resolved []string
+	if mode != CommonPathsReplace {
+		resolved = append(resolved, DefaultCommonFeedPaths...)
+	}
+
+	seen := make(map[string]bool, len(resolved)+len(paths))
+	for _, path := range resolved {
+		seen[path] = true
+	}
+
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		resolved = append(resolved, path)
+	}
+
+	return resolved
 }
 
 // DiscoverFeed attempts to discover and validate an RSS/Atom feed from a given URL
@@ -47,22 +307,66 @@ func DiscoverFeed(pageURL string, httpClient *HTTPClient, userAgent string) *Fee
 
 // DiscoverFeedWithDebug attempts to discover and validate an RSS/Atom feed from a given URL with debug options
 func DiscoverFeedWithDebug(pageURL string, httpClient *HTTPClient, userAgent string, saveFailedHTML bool, debugOutputDir string) *FeedDiscoveryResult {
+	return DiscoverFeedWithOptions(context.Background(), pageURL, DiscoveryOptions{
+		HTTPClient:     httpClient,
+		UserAgent:      userAgent,
+		SaveFailedHTML: saveFailedHTML,
+		DebugOutputDir: debugOutputDir,
+	})
+}
+
+// DiscoverFeedWithOptions attempts to discover and validate an RSS/Atom feed from a given URL.
+// Outstanding requests are abandoned when ctx is cancelled.
+func DiscoverFeedWithOptions(ctx context.Context, pageURL string, opts DiscoveryOptions) *FeedDiscoveryResult {
 	result := &FeedDiscoveryResult{
 		URL: pageURL,
 	}
 
+	httpClient := opts.HTTPClient
+	userAgent := opts.UserAgent
+	saveFailedHTML := opts.SaveFailedHTML
+	debugOutputDir := opts.DebugOutputDir
+
 	logrus.WithField("url", pageURL).Debug("Starting feed autodiscovery")
 
 	// Step 1: Fetch the webpage
-	pageContent, err := httpClient.FetchPage(pageURL, userAgent)
+	var pageResp *PageResponse
+	err := retryOperation(ctx, opts.PageRetryAttempts, opts.PageRetryBackoff, "fetch page "+pageURL, func() error {
+		var fetchErr error
+		pageResp, fetchErr = httpClient.Fetch(ctx, pageURL, userAgent)
+		return fetchErr
+	})
 	if err != nil {
-		result.Error = fmt.Errorf("failed to fetch page: %w", err)
+		if isAuthRequiredError(err) {
+			result.Error = fmt.Errorf("failed to fetch page: %w: %w", ErrAuthRequired, err)
+			logrus.WithFields(logrus.Fields{
+				"url":   pageURL,
+				"error": err,
+			}).Warn("Feed discovery failed: page requires authentication")
+			return result
+		}
+
+		if isPageGoneError(err) {
+			result.Error = fmt.Errorf("failed to fetch page: %w: %w", ErrPageGone, err)
+		} else {
+			result.Error = fmt.Errorf("failed to fetch page: %w", err)
+		}
+
+		// Cancellation isn't a problem with the page, so keep it out of the warnings
+		if ctx.Err() != nil {
+			logrus.WithField("url", pageURL).Debug("Feed discovery cancelled while fetching page")
+			return result
+		}
+
 		logrus.WithFields(logrus.Fields{
 			"url":   pageURL,
 			"error": err,
 		}).Warn("Feed discovery failed: could not fetch page")
 		return result
 	}
+	pageContent := pageResp.Body
+	result.CacheTTL = pageResp.MaxAge
+	result.CacheNoStore = pageResp.NoStore
 
 	logrus.WithFields(logrus.Fields{
 		"url":             pageURL,
@@ -70,23 +374,61 @@ func DiscoverFeedWithDebug(pageURL string, httpClient *HTTPClient, userAgent str
 		"content_preview": getContentPreview(pageContent, 200),
 	}).Debug("Successfully fetched page for feed discovery")
 
-	// Step 1.5: Check if pageContent is itself an RSS/Atom feed
-	if feedTitle, err := extractFeedTitle(pageContent); err == nil {
-		result.FeedURL = pageURL
-		result.FeedTitle = feedTitle
+	// Step 1.5: Check if the bookmark points straight at a feed, using the
+	// response content type and a sniff of the body as hints
+	contentAnalysis := analyzeContentType(pageContent)
+	looksLikeFeed := isFeedContentType(pageResp.ContentType) || isFeedContentAnalysis(contentAnalysis)
+
+	metadata, err := parseFeedMetadata(pageContent, pageResp.ContentType)
+	if err == nil {
+		err = opts.ensureFeedTitle(metadata, pageURL, "")
+	}
+	if err == nil {
+		result.applyFeedMetadata(pageResp.FinalURL, metadata)
+		if opts.ValidateFeed {
+			result.Warnings = ValidateFeed(pageContent)
+		}
 
 		logrus.WithFields(logrus.Fields{
-			"page_url":   pageURL,
-			"feed_title": feedTitle,
+			"page_url":     pageURL,
+			"feed_title":   metadata.Title,
+			"content_type": pageResp.ContentType,
+			"status_code":  pageResp.StatusCode,
 		}).Info("Page URL is itself a feed")
 
 		return result
 	}
 
+	// A feed document won't contain HTML feed links, so don't go looking for them
+	if looksLikeFeed {
+		result.Error = fmt.Errorf("page looks like a feed (%s) but could not be parsed: %w", describeFeedHint(pageResp.ContentType, contentAnalysis), err)
+		logrus.WithFields(logrus.Fields{
+			"url":              pageURL,
+			"content_type":     pageResp.ContentType,
+			"content_analysis": contentAnalysis,
+			"error":            err,
+		}).Warn("Feed discovery failed: page looks like a feed but could not be parsed")
+		return result
+	}
+
 	// Step 2: Parse HTML and find feed links
-	feedURLs := findFeedLinks(pageContent, pageURL)
-	if len(feedURLs) == 0 {
-		result.Error = fmt.Errorf("no feed links found in page")
+	commonPaths := opts.CommonPaths
+	if commonPaths == nil {
+		commonPaths = DefaultCommonFeedPaths
+	}
+	if opts.NoCommonPaths {
+		commonPaths = nil
+	}
+	candidates := findFeedLinks(pageContent, pageURL, commonPaths)
+	if len(candidates) == 0 {
+		// CDNs often answer unfamiliar user agents with an error page and a 200
+		if shouldRetryBlocked(ctx, opts, pageContent) {
+			if retried := retryWithBrowserUserAgent(ctx, pageURL, opts); retried.IsSuccessful() {
+				return retried
+			}
+		}
+
+		result.Error = ErrNoFeedLinks
 
 		// Save failed HTML for debugging if requested
 		if saveFailedHTML && debugOutputDir != "" {
@@ -113,20 +455,31 @@ func DiscoverFeedWithDebug(pageURL string, httpClient *HTTPClient, userAgent str
 	// Step 3: Try each feed URL found until we get one that works
 	logrus.WithFields(logrus.Fields{
 		"page_url":    pageURL,
-		"total_found": len(feedURLs),
-		"all_feeds":   feedURLs,
+		"total_found": len(candidates),
+		"all_feeds":   candidateURLs(candidates),
 	}).Info("Found potential feed links, trying each one")
 
-	for i, feedURL := range feedURLs {
+	for i, candidate := range candidates {
+		feedURL := candidate.URL
+		if ctx.Err() != nil {
+			result.Error = fmt.Errorf("feed discovery cancelled: %w", ctx.Err())
+			return result
+		}
+
 		logrus.WithFields(logrus.Fields{
 			"page_url": pageURL,
 			"feed_url": feedURL,
 			"attempt":  i + 1,
-			"total":    len(feedURLs),
+			"total":    len(candidates),
 		}).Debug("Attempting to fetch feed")
 
+		// Common paths are guesses, so check cheaply that one exists first
+		if candidate.Source == CandidateCommonPath && !probeCommonPath(ctx, feedURL, opts) {
+			continue
+		}
+
 		// Step 4: Fetch and validate the feed
-		feedContent, err := httpClient.FetchPage(feedURL, userAgent)
+		feedResp, err := fetchFeedContent(ctx, feedURL, opts)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"page_url": pageURL,
@@ -136,6 +489,7 @@ func DiscoverFeedWithDebug(pageURL string, httpClient *HTTPClient, userAgent str
 			}).Debug("Failed to fetch this feed URL, trying next")
 			continue
 		}
+		feedContent := feedResp.Body
 
 		logrus.WithFields(logrus.Fields{
 			"page_url":     pageURL,
@@ -144,8 +498,11 @@ func DiscoverFeedWithDebug(pageURL string, httpClient *HTTPClient, userAgent str
 			"feed_preview": getContentPreview(feedContent, 200),
 		}).Debug("Successfully fetched feed content")
 
-		// Step 5: Parse feed and extract title
-		feedTitle, err := extractFeedTitle(feedContent)
+		// Step 5: Parse feed and extract metadata
+		metadata, err := parseFeedMetadata(feedContent, feedResp.ContentType)
+		if err == nil {
+			err = opts.ensureFeedTitle(metadata, pageURL, pageContent)
+		}
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"page_url":     pageURL,
@@ -158,22 +515,41 @@ func DiscoverFeedWithDebug(pageURL string, httpClient *HTTPClient, userAgent str
 			continue
 		}
 
-		// Success!
-		result.FeedURL = feedURL
-		result.FeedTitle = feedTitle
+		// Success! Record the final URL so the OPML doesn't point at a redirect
+		if feedResp.FinalURL != feedURL {
+			logrus.WithFields(logrus.Fields{
+				"feed_url":  feedURL,
+				"final_url": feedResp.FinalURL,
+			}).Info("Feed URL redirects, using final URL")
+		}
+		result.applyFeedMetadata(feedResp.FinalURL, metadata)
+		if result.CacheTTL == 0 && !result.CacheNoStore {
+			result.CacheTTL = feedResp.MaxAge
+			result.CacheNoStore = feedResp.NoStore
+		}
+		if opts.ValidateFeed {
+			result.Warnings = ValidateFeed(feedContent)
+		}
 
 		logrus.WithFields(logrus.Fields{
 			"page_url":   pageURL,
-			"feed_url":   feedURL,
-			"feed_title": feedTitle,
+			"feed_url":   result.FeedURL,
+			"feed_title": result.FeedTitle,
 			"attempt":    i + 1,
 		}).Info("Feed discovery successful")
 
 		return result
 	}
 
-	// If we get here, none of the feed URLs worked
-	result.Error = fmt.Errorf("found %d potential feed URLs but none were valid feeds", len(feedURLs))
+	// If we get here, none of the feed URLs worked. The probed common paths
+	// were likely blocked the same way as a soft-blocked page.
+	if shouldRetryBlocked(ctx, opts, pageContent) {
+		if retried := retryWithBrowserUserAgent(ctx, pageURL, opts); retried.IsSuccessful() {
+			return retried
+		}
+	}
+
+	result.Error = fmt.Errorf("found %d potential feed URLs but none were valid feeds", len(candidates))
 
 	// Save failed HTML for debugging if requested
 	if saveFailedHTML && debugOutputDir != "" {
@@ -181,22 +557,131 @@ func DiscoverFeedWithDebug(pageURL string, httpClient *HTTPClient, userAgent str
 		logrus.WithFields(logrus.Fields{
 			"page_url":        pageURL,
 			"saved_html":      savedPath,
-			"attempted_feeds": len(feedURLs),
+			"attempted_feeds": len(candidates),
 		}).Debug("Saved failed HTML for debugging")
 	}
 
 	logrus.WithFields(logrus.Fields{
 		"page_url":        pageURL,
-		"attempted_feeds": len(feedURLs),
-		"all_feeds":       feedURLs,
+		"attempted_feeds": len(candidates),
+		"all_feeds":       candidateURLs(candidates),
 	}).Warn("Feed discovery failed: no valid feeds found among candidates")
 
 	return result
 }
 
-// findFeedLinks parses HTML content and extracts RSS/Atom feed URLs using autodiscovery
-func findFeedLinks(htmlContent, baseURL string) []string {
-	var feedURLs []string
+// isAuthRequiredError returns true if err is a 401 Unauthorized or 403 Forbidden response
+func isAuthRequiredError(err error) bool {
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	return statusErr.StatusCode == http.StatusUnauthorized || statusErr.StatusCode == http.StatusForbidden
+}
+
+// isPageGoneError returns true if err is a 404 Not Found or 410 Gone response,
+// or a DNS lookup that found no such host
+func isPageGoneError(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusGone
+	}
+
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// IsSkipped returns true if the bookmark was not probed because it is on the skip list
+func (r *FeedDiscoveryResult) IsSkipped() bool {
+	return errors.Is(r.Error, ErrSkipped)
+}
+
+// IsUnsupportedScheme returns true if the bookmark was not probed because its URL isn't http or https
+func (r *FeedDiscoveryResult) IsUnsupportedScheme() bool {
+	return errors.Is(r.Error, ErrUnsupportedScheme)
+}
+
+// IsNoFeedLinks returns true if discovery failed because the page has no feed links
+func (r *FeedDiscoveryResult) IsNoFeedLinks() bool {
+	return errors.Is(r.Error, ErrNoFeedLinks)
+}
+
+// IsAuthRequired returns true if discovery failed because the page requires authentication
+func (r *FeedDiscoveryResult) IsAuthRequired() bool {
+	return errors.Is(r.Error, ErrAuthRequired)
+}
+
+// IsPageGone returns true if discovery failed because the bookmark page no longer exists
+func (r *FeedDiscoveryResult) IsPageGone() bool {
+	return errors.Is(r.Error, ErrPageGone)
+}
+
+// fetchFeedContent fetches a candidate feed URL using the feed-specific client,
+// retrying transient failures according to the discovery options
+func fetchFeedContent(ctx context.Context, feedURL string, opts DiscoveryOptions) (*PageResponse, error) {
+	client := opts.FeedClient
+	if client == nil {
+		client = opts.HTTPClient
+	}
+
+	var resp *PageResponse
+	err := retryOperation(ctx, opts.FeedRetryAttempts, opts.FeedRetryBackoff, "fetch feed "+feedURL, func() error {
+		var fetchErr error
+		resp, fetchErr = client.FetchFeed(ctx, feedURL, opts.UserAgent)
+		return fetchErr
+	})
+
+	return resp, err
+}
+
+// verifyFeed fetches a previously discovered feed URL and checks that it
+// still parses as a feed
+func verifyFeed(ctx context.Context, feedURL string, opts DiscoveryOptions) error {
+	resp, err := fetchFeedContent(ctx, feedURL, opts)
+	if err != nil {
+		return err
+	}
+	_, err = parseFeedMetadata(resp.Body, resp.ContentType)
+	return err
+}
+
+// probeCommonPath sends a HEAD request for a guessed feed URL and reports
+// whether it is worth fetching. Only a clear answer rules the URL out: a 404
+// or 410, or an HTML page (typically a site's catch-all route). Servers that
+// reject HEAD or fail to answer it get the full GET as before.
+func probeCommonPath(ctx context.Context, feedURL string, opts DiscoveryOptions) bool {
+	client := opts.FeedClient
+	if client == nil {
+		client = opts.HTTPClient
+	}
+
+	resp, err := client.Head(ctx, feedURL, opts.UserAgent)
+	if err != nil {
+		if isPageGoneError(err) {
+			logrus.WithFields(logrus.Fields{
+				"feed_url": feedURL,
+				"error":    err,
+			}).Debug("Common feed path does not exist, skipping")
+			return false
+		}
+		return true
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.ContentType)
+	if mediaType == "text/html" {
+		logrus.WithFields(logrus.Fields{
+			"feed_url":     feedURL,
+			"content_type": resp.ContentType,
+		}).Debug("Common feed path serves HTML, skipping")
+		return false
+	}
+	return true
+}
+
+// findFeedLinks parses HTML content and extracts RSS/Atom feed candidates using
+// autodiscovery, ranked so the most likely main feed comes first
+func findFeedLinks(htmlContent, baseURL string, commonPaths []string) []FeedCandidate {
+	var candidates []FeedCandidate
 
 	logrus.WithFields(logrus.Fields{
 		"base_url":     baseURL,
@@ -207,11 +692,12 @@ func findFeedLinks(htmlContent, baseURL string) []string {
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
 		logrus.WithError(err).Debug("Failed to parse HTML, falling back to regex")
-		return findFeedLinksRegex(htmlContent, baseURL)
+		return RankFeedCandidates(candidatesFrom(findFeedLinksRegex(htmlContent, baseURL), CandidateRegex))
 	}
 
 	linkCount := 0
 	alternateCount := 0
+	var anchors []FeedCandidate
 
 	// Walk the HTML tree looking for link elements
 	var walkNode func(*html.Node)
@@ -250,11 +736,7 @@ func findFeedLinks(htmlContent, baseURL string) []string {
 				alternateCount++
 
 				// Check for feed types (be more permissive)
-				isFeedType := strings.Contains(typLower, "application/rss+xml") ||
-					strings.Contains(typLower, "application/atom+xml") ||
-					strings.Contains(typLower, "application/rdf+xml") ||
-					strings.Contains(typLower, "text/xml") ||
-					strings.Contains(typLower, "application/xml")
+				isFeedType := isFeedLinkType(typLower)
 
 				// Also check href for common feed patterns
 				hrefLower := strings.ToLower(href)
@@ -266,7 +748,11 @@ func findFeedLinks(htmlContent, baseURL string) []string {
 				if isFeedType || (strings.Contains(relLower, "alternate") && hasCommonFeedPath) {
 					// Convert relative URLs to absolute
 					if feedURL := resolveURL(href, baseURL); feedURL != "" {
-						feedURLs = append(feedURLs, feedURL)
+						candidates = append(candidates, FeedCandidate{
+							URL:    feedURL,
+							Title:  title,
+							Source: CandidateLink,
+						})
 						logrus.WithFields(logrus.Fields{
 							"base_url":     baseURL,
 							"rel":          rel,
@@ -290,6 +776,12 @@ func findFeedLinks(htmlContent, baseURL string) []string {
 			}
 		}
 
+		if n.Type == html.ElementNode && n.Data == "a" {
+			if candidate, ok := anchorFeedCandidate(n, baseURL); ok {
+				anchors = append(anchors, candidate)
+			}
+		}
+
 		// Recursively walk child nodes
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
 			walkNode(c)
@@ -302,36 +794,216 @@ func findFeedLinks(htmlContent, baseURL string) []string {
 		"base_url":         baseURL,
 		"total_link_tags":  linkCount,
 		"alternate_links":  alternateCount,
-		"feed_links_found": len(feedURLs),
+		"feed_links_found": len(candidates),
+		"feed_anchors":     len(anchors),
 	}).Debug("HTML parsing complete")
 
 	// If we didn't find any feeds with HTML parsing, try regex as fallback
-	if len(feedURLs) == 0 {
+	if len(candidates) == 0 {
 		logrus.WithField("base_url", baseURL).Debug("No feeds found with HTML parser, trying regex fallback")
-		feedURLs = findFeedLinksRegex(htmlContent, baseURL)
+		candidates = candidatesFrom(findFeedLinksRegex(htmlContent, baseURL), CandidateRegex)
 
-		if len(feedURLs) > 0 {
+		if len(candidates) > 0 {
 			logrus.WithFields(logrus.Fields{
 				"base_url":    baseURL,
-				"regex_found": len(feedURLs),
+				"regex_found": len(candidates),
 			}).Info("Regex fallback found feeds that HTML parsing missed")
 		}
 	}
 
+	// Feed links in the page body, for sites that only advertise their feed there
+	candidates = appendNewCandidates(candidates, anchors)
+
 	// Try common feed paths as last resort
-	if len(feedURLs) == 0 {
+	if len(candidates) == 0 {
 		logrus.WithField("base_url", baseURL).Debug("No feeds found, trying common feed paths")
-		feedURLs = tryCommonFeedPaths(baseURL)
+		candidates = candidatesFrom(tryCommonFeedPaths(baseURL, commonPaths), CandidateCommonPath)
 
-		if len(feedURLs) > 0 {
+		if len(candidates) > 0 {
 			logrus.WithFields(logrus.Fields{
 				"base_url":           baseURL,
-				"common_paths_found": len(feedURLs),
+				"common_paths_found": len(candidates),
 			}).Info("Common feed paths found feeds")
 		}
 	}
 
-	return feedURLs
+	// WordPress sites reliably serve their main feed at /feed/, even when the
+	// autodiscovery links are missing or only point at comment feeds
+	if root, ok := wordPressRoot(htmlContent, baseURL); ok {
+		logrus.WithFields(logrus.Fields{
+			"base_url":       baseURL,
+			"wordpress_root": root,
+		}).Info("WordPress site detected, adding /feed/ candidates")
+		candidates = addWordPressCandidates(candidates, root)
+	}
+
+	return RankFeedCandidates(candidates)
+}
+
+// isFeedLinkType returns true if a lowercased type attribute names a feed format
+func isFeedLinkType(typLower string) bool {
+	return strings.Contains(typLower, "application/rss+xml") ||
+		strings.Contains(typLower, "application/atom+xml") ||
+		strings.Contains(typLower, "application/rdf+xml") ||
+		strings.Contains(typLower, "application/feed+json") ||
+		strings.Contains(typLower, "text/xml") ||
+		strings.Contains(typLower, "application/xml")
+}
+
+// strongFeedPathSuffixes are path endings that almost always mean a feed,
+// unlike a path that merely contains "feed" or "rss" somewhere
+var strongFeedPathSuffixes = []string{
+	"/feed", "/rss", "/atom",
+	".rss", ".atom", "/feed.xml", "/rss.xml", "/atom.xml", "/index.xml", "/feed.json",
+}
+
+// anchorFeedCandidate returns a candidate for an <a> element that links to a
+// feed. Since pages link to all kinds of things, an anchor only counts when
+// its type attribute is a feed type, or when it points at a strong feed path
+// on the page's own host (so a blogroll of other sites' feeds is ignored).
+func anchorFeedCandidate(n *html.Node, baseURL string) (FeedCandidate, bool) {
+	var typ, href, title string
+	for _, attr := range n.Attr {
+		switch strings.ToLower(attr.Key) {
+		case "type":
+			typ = attr.Val
+		case "href":
+			href = attr.Val
+		case "title":
+			title = attr.Val
+		}
+	}
+	if href == "" {
+		return FeedCandidate{}, false
+	}
+
+	feedURL := resolveURL(href, baseURL)
+	if feedURL == "" {
+		return FeedCandidate{}, false
+	}
+
+	if !isFeedLinkType(strings.ToLower(typ)) && !isStrongFeedPath(feedURL, baseURL) {
+		return FeedCandidate{}, false
+	}
+
+	if title == "" {
+		title = strings.Join(strings.Fields(nodeText(n)), " ")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"base_url": baseURL,
+		"type":     typ,
+		"href":     href,
+		"resolved": feedURL,
+	}).Debug("Found feed link in page body")
+
+	return FeedCandidate{URL: feedURL, Title: title, Source: CandidateAnchor}, true
+}
+
+// isStrongFeedPath returns true if feedURL is on baseURL's host and its path
+// ends like a feed URL
+func isStrongFeedPath(feedURL, baseURL string) bool {
+	feed, err := url.Parse(feedURL)
+	if err != nil {
+		return false
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil || !strings.EqualFold(feed.Hostname(), base.Hostname()) {
+		return false
+	}
+
+	path := strings.TrimSuffix(strings.ToLower(feed.Path), "/")
+	for _, suffix := range strongFeedPathSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeText returns the text content of an HTML node and its descendants
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+
+	var text strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		text.WriteString(nodeText(c))
+	}
+	return text.String()
+}
+
+// appendNewCandidates appends the extra candidates whose URLs aren't already
+// among candidates
+func appendNewCandidates(candidates, extra []FeedCandidate) []FeedCandidate {
+	seen := make(map[string]bool, len(candidates)+len(extra))
+	for _, candidate := range candidates {
+		seen[candidate.URL] = true
+	}
+
+	for _, candidate := range extra {
+		if seen[candidate.URL] {
+			continue
+		}
+		seen[candidate.URL] = true
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// wordPressGeneratorRegex matches <meta name="generator" content="WordPress ...">
+var wordPressGeneratorRegex = regexp.MustCompile(`(?i)<meta[^>]+name=["']generator["'][^>]+content=["']WordPress`)
+
+// wordPressPathRegex matches URLs into a WordPress install's wp-content or
+// wp-json, capturing the optional host and the install's path prefix
+var wordPressPathRegex = regexp.MustCompile(`(?i)(?:(?:https?:)?//([^/"'\s<>]+))?((?:/[^/"'\s<>?#]+)*?)/wp-(?:content|json)(?:[/"'?\s]|$)`)
+
+// wordPressRoot reports whether the page at pageURL is served by WordPress,
+// judged by its generator meta tag or by wp-content/wp-json URLs on the
+// page's own host, and returns the URL of the WordPress install, which may be
+// in a subdirectory. wp-content URLs on other hosts, such as images
+// hotlinked from another WordPress site, don't count.
+func wordPressRoot(htmlContent, pageURL string) (string, bool) {
+	page, err := url.Parse(pageURL)
+	if err != nil || page.Host == "" {
+		return "", false
+	}
+	origin := page.Scheme + "://" + page.Host
+
+	for _, match := range wordPressPathRegex.FindAllStringSubmatch(htmlContent, -1) {
+		host, installPath := match[1], match[2]
+		if host == "" || strings.EqualFold(host, page.Host) {
+			return origin + installPath, true
+		}
+	}
+
+	if wordPressGeneratorRegex.MatchString(htmlContent) {
+		return origin, true
+	}
+	return "", false
+}
+
+// addWordPressCandidates adds the canonical /feed/ and /comments/feed/ of the
+// WordPress install at root to the candidates, skipping any that were already
+// discovered. Ranking then puts the main feed ahead of the comments feed.
+func addWordPressCandidates(candidates []FeedCandidate, root string) []FeedCandidate {
+	root = strings.TrimSuffix(root, "/")
+
+	// Treat /feed and /feed/ as the same candidate
+	seen := make(map[string]bool, len(candidates))
+	for _, candidate := range candidates {
+		seen[strings.TrimSuffix(candidate.URL, "/")] = true
+	}
+
+	for _, feedURL := range []string{root + "/feed/", root + "/comments/feed/"} {
+		if seen[strings.TrimSuffix(feedURL, "/")] {
+			continue
+		}
+		candidates = append(candidates, FeedCandidate{URL: feedURL, Source: CandidateWordPress})
+	}
+
+	return candidates
 }
 
 // getMatchReason returns a human-readable reason why a link was matched as a feed
@@ -348,8 +1020,9 @@ func getMatchReason(isFeedType, hasCommonFeedPath bool) string {
 	return "unknown"
 }
 
-// tryCommonFeedPaths attempts to find feeds at common locations
-func tryCommonFeedPaths(baseURL string) []string {
+// tryCommonFeedPaths builds the candidate URLs for the common feed
+// locations on baseURL's host, in order and without duplicates
+func tryCommonFeedPaths(baseURL string, commonPaths []string) []string {
 	var feedURLs []string
 
 	// Parse the base URL to build common feed paths
@@ -358,20 +1031,13 @@ func tryCommonFeedPaths(baseURL string) []string {
 		return feedURLs
 	}
 
-	// Common feed paths to try
-	commonPaths := []string{
-		"/feed",
-		"/feed.xml",
-		"/rss",
-		"/rss.xml",
-		"/atom.xml",
-		"/feeds/all.atom.xml",
-		"/index.xml",
-		"/.rss",
-	}
-
+	seen := make(map[string]bool, len(commonPaths))
 	for _, path := range commonPaths {
 		feedURL := base.Scheme + "://" + base.Host + path
+		if seen[feedURL] {
+			continue
+		}
+		seen[feedURL] = true
 		feedURLs = append(feedURLs, feedURL)
 
 		logrus.WithFields(logrus.Fields{
@@ -440,21 +1106,363 @@ func resolveURL(href, baseURL string) string {
 	return resolved.String()
 }
 
-// extractFeedTitle parses RSS or Atom feed content and extracts the title
-func extractFeedTitle(feedContent string) (string, error) {
-	// Try parsing as RSS first
+// feedParser is a parser for one feed format
+type feedParser struct {
+	feedType string
+	parse    func(string) (*feedMetadata, bool)
+}
+
+// feedParsers are tried in this order when nothing hints at the format
+var feedParsers = []feedParser{
+	{FeedTypeRSS, parseRSSMetadata},
+	{FeedTypeAtom, parseAtomMetadata},
+	{FeedTypeRDF, parseRDFMetadata},
+	{FeedTypeJSON, parseJSONFeedMetadata},
+}
+
+// parseFeedMetadata parses RSS, Atom, RDF or JSON Feed content and extracts
+// the title and self URL. The format named by the response content type, or
+// else sniffed from the content, is tried first; the others follow in case
+// the hint is wrong. A feed without a title is returned with an empty Title
+// only when no parser finds one.
+func parseFeedMetadata(feedContent, contentType string) (*feedMetadata, error) {
+	feedContent = trimFeedPrefix(feedContent)
+
+	likely := likelyFeedType(contentType, feedContent)
+	parsers := make([]func(string) (*feedMetadata, bool), 0, len(feedParsers)+1)
+	for _, parser := range feedParsers {
+		if parser.feedType == likely {
+			parsers = append(parsers, parser.parse)
+		}
+	}
+	for _, parser := range feedParsers {
+		if parser.feedType != likely {
+			parsers = append(parsers, parser.parse)
+		}
+	}
+	parsers = append(parsers, scanFeedMetadata)
+
+	var untitled *feedMetadata
+	for _, parse := range parsers {
+		metadata, ok := parse(feedContent)
+		if !ok {
+			continue
+		}
+		if metadata.Title != "" {
+			return metadata, nil
+		}
+		if untitled == nil {
+			untitled = metadata
+		}
+	}
+	if untitled != nil {
+		return untitled, nil
+	}
+
+	return nil, fmt.Errorf("could not extract title from feed (not valid RSS, Atom, RDF or JSON Feed)")
+}
+
+// parseRSSMetadata extracts metadata from an RSS 2.0 feed
+func parseRSSMetadata(feedContent string) (*feedMetadata, bool) {
 	var rss RSS
-	if err := xml.Unmarshal([]byte(feedContent), &rss); err == nil && rss.Channel.Title != "" {
-		return strings.TrimSpace(rss.Channel.Title), nil
+	if err := decodeFeedXML(feedContent, &rss); err != nil {
+		return nil, false
 	}
 
-	// Try parsing as Atom
+	return &feedMetadata{
+		Title:    strings.TrimSpace(rss.Channel.Title),
+		SelfURL:  findLinkHref(channelAtomLinks(rss.Channel.Links), "self"),
+		HubURL:   findLinkHref(channelAtomLinks(rss.Channel.Links), "hub"),
+		Language: strings.TrimSpace(rss.Channel.Language),
+		FeedType: FeedTypeRSS,
+		IconURL:  strings.TrimSpace(rss.Channel.Image.URL),
+		SiteURL:  channelSiteURL(rss.Channel.Links),
+
+		ItemCount:   len(rss.Channel.Items),
+		LastUpdated: latestItemDate(rss.Channel.Items, rss.Channel.LastBuildDate, rss.Channel.PubDate),
+	}, true
+}
+
+// parseAtomMetadata extracts metadata from an Atom feed
+func parseAtomMetadata(feedContent string) (*feedMetadata, bool) {
 	var atom Atom
-	if err := xml.Unmarshal([]byte(feedContent), &atom); err == nil && atom.Title != "" {
-		return strings.TrimSpace(atom.Title), nil
+	if err := decodeFeedXML(feedContent, &atom); err != nil {
+		return nil, false
+	}
+
+	return &feedMetadata{
+		Title:    strings.TrimSpace(atom.Title),
+		SelfURL:  findLinkHref(atom.Links, "self"),
+		HubURL:   findLinkHref(atom.Links, "hub"),
+		Language: strings.TrimSpace(atom.Lang),
+		FeedType: FeedTypeAtom,
+		IconURL:  firstNonEmpty(atom.Icon, atom.Logo),
+		SiteURL:  findLinkHref(atom.Links, "alternate"),
+
+		ItemCount:   len(atom.Entries),
+		LastUpdated: latestItemDate(atom.Entries, atom.Updated),
+	}, true
+}
+
+// parseRDFMetadata extracts metadata from an RSS 1.0 (RDF) feed
+func parseRDFMetadata(feedContent string) (*feedMetadata, bool) {
+	var rdf RDF
+	if err := decodeFeedXML(feedContent, &rdf); err != nil {
+		return nil, false
+	}
+
+	return &feedMetadata{
+		Title:    strings.TrimSpace(rdf.Channel.Title),
+		SelfURL:  findLinkHref(channelAtomLinks(rdf.Channel.Links), "self"),
+		HubURL:   findLinkHref(channelAtomLinks(rdf.Channel.Links), "hub"),
+		Language: strings.TrimSpace(rdf.Channel.Language),
+		FeedType: FeedTypeRDF,
+		IconURL:  strings.TrimSpace(rdf.Image.URL),
+		SiteURL:  channelSiteURL(rdf.Channel.Links),
+
+		ItemCount:   len(rdf.Items),
+		LastUpdated: latestItemDate(rdf.Items, rdf.Channel.DCDate),
+	}, true
+}
+
+// parseJSONFeedMetadata extracts metadata from a JSON Feed (https://jsonfeed.org)
+func parseJSONFeedMetadata(feedContent string) (*feedMetadata, bool) {
+	var feed JSONFeed
+	if err := json.Unmarshal([]byte(feedContent), &feed); err != nil {
+		return nil, false
+	}
+	if !strings.HasPrefix(feed.Version, "https://jsonfeed.org/version/") {
+		return nil, false
+	}
+
+	var dates []string
+	for _, item := range feed.Items {
+		dates = append(dates, item.DatePublished, item.DateModified)
+	}
+
+	return &feedMetadata{
+		Title:    strings.TrimSpace(feed.Title),
+		SelfURL:  strings.TrimSpace(feed.FeedURL),
+		HubURL:   jsonFeedHub(feed),
+		Language: strings.TrimSpace(feed.Language),
+		FeedType: FeedTypeJSON,
+		IconURL:  firstNonEmpty(feed.Icon, feed.Favicon),
+		SiteURL:  strings.TrimSpace(feed.HomePageURL),
+
+		ItemCount:   len(feed.Items),
+		LastUpdated: latestFeedDate(dates...),
+	}, true
+}
+
+// feedRootTypes maps the lowercased local name of a feed's root element to its format
+var feedRootTypes = map[string]string{
+	"rss":  FeedTypeRSS,
+	"feed": FeedTypeAtom,
+	"rdf":  FeedTypeRDF,
+}
+
+// scanFeedMetadata is the last resort for XML feeds the structured parsers
+// reject, such as feeds that are malformed or truncated after their title, or
+// whose channel title is overwritten by a later, empty namespaced title. It
+// scans for the first non-empty title directly inside the channel, or the
+// root of an Atom feed, whatever its namespace prefix. Only the title and
+// format are extracted.
+func scanFeedMetadata(feedContent string) (*feedMetadata, bool) {
+	decoder := xml.NewDecoder(strings.NewReader(feedContent))
+	decoder.CharsetReader = charset.NewReaderLabel
+	decoder.Strict = false
+
+	var feedType string
+	var path []string // lowercased local names of the open elements
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, false
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if len(path) == 0 {
+				if feedType = feedRootTypes[name]; feedType == "" {
+					return nil, false
+				}
+			}
+
+			if name == "title" && isFeedTitleParent(feedType, path) {
+				// Decoding the element consumes its end tag and unwraps CDATA
+				var title string
+				if err := decoder.DecodeElement(&title, &t); err != nil {
+					return nil, false
+				}
+				if title = strings.TrimSpace(title); title != "" {
+					return &feedMetadata{Title: title, FeedType: feedType}, true
+				}
+				continue
+			}
+			path = append(path, name)
+		case xml.EndElement:
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		}
+	}
+}
+
+// isFeedTitleParent reports whether a title inside the open elements in path
+// is the feed's own title rather than an item's or an image's
+func isFeedTitleParent(feedType string, path []string) bool {
+	if feedType == FeedTypeAtom {
+		return len(path) == 1
+	}
+	return len(path) >= 2 && path[len(path)-1] == "channel"
+}
+
+// findLinkHref returns the href of the first link with the given rel, or empty string
+func findLinkHref(links []AtomLink, rel string) string {
+	for _, link := range links {
+		if strings.EqualFold(strings.TrimSpace(link.Rel), rel) && strings.TrimSpace(link.Href) != "" {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	return ""
+}
+
+// jsonFeedHub returns the URL of the JSON Feed's WebSub hub, if it lists one
+func jsonFeedHub(feed JSONFeed) string {
+	for _, hub := range feed.Hubs {
+		if strings.EqualFold(strings.TrimSpace(hub.Type), "websub") && strings.TrimSpace(hub.URL) != "" {
+			return strings.TrimSpace(hub.URL)
+		}
+	}
+	return ""
+}
+
+// firstNonEmpty returns the first value that isn't blank, trimmed
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// resolveIconURL resolves a feed's icon URL against the site link the feed
+// declares, falling back to the URL the feed was fetched from. Icons that
+// don't resolve to http(s) URLs are dropped.
+func resolveIconURL(metadata *feedMetadata, fetchedURL string) string {
+	if metadata.IconURL == "" {
+		return ""
+	}
+
+	base := fetchedURL
+	if metadata.SiteURL != "" {
+		if siteURL := resolveURL(metadata.SiteURL, fetchedURL); siteURL != "" {
+			base = siteURL
+		}
+	}
+
+	iconURL := resolveURL(metadata.IconURL, base)
+	if parsed, err := url.Parse(iconURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return ""
+	}
+	return iconURL
+}
+
+// resolveHTTPURL resolves href against baseURL, returning "" unless the result is an http(s) URL
+func resolveHTTPURL(href, baseURL string) string {
+	if href == "" {
+		return ""
+	}
+	resolved := resolveURL(href, baseURL)
+	if parsed, err := url.Parse(resolved); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return ""
+	}
+	return resolved
+}
+
+// applyFeedMetadata records a successfully parsed feed on the result, preferring
+// the feed's self-declared URL over the URL it was fetched from
+func (r *FeedDiscoveryResult) applyFeedMetadata(fetchedURL string, metadata *feedMetadata) {
+	r.FetchedURL = fetchedURL
+	r.FeedURL = fetchedURL
+	r.FeedTitle = metadata.Title
+	r.Language = metadata.Language
+	r.FeedType = metadata.FeedType
+	r.IconURL = resolveIconURL(metadata, fetchedURL)
+	r.HubURL = resolveHTTPURL(metadata.HubURL, fetchedURL)
+	r.ItemCount = metadata.ItemCount
+	r.LastUpdated = metadata.LastUpdated
+	r.ActivityKnown = true
+
+	if metadata.SelfURL == "" {
+		return
+	}
+
+	selfURL := resolveURL(metadata.SelfURL, fetchedURL)
+	if parsed, err := url.Parse(selfURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		logrus.WithFields(logrus.Fields{
+			"fetched_url": fetchedURL,
+			"self_url":    metadata.SelfURL,
+		}).Debug("Ignoring unusable self URL declared by feed")
+		return
+	}
+
+	if selfURL != fetchedURL {
+		logrus.WithFields(logrus.Fields{
+			"fetched_url": fetchedURL,
+			"self_url":    selfURL,
+		}).Info("Feed declares a different self URL, using it for the OPML outline")
+		r.FeedURL = selfURL
+	}
+}
+
+// feedSniffLength is how much of a document likelyFeedType inspects for its root element
+const feedSniffLength = 512
+
+// likelyFeedType guesses a document's feed format from its content type or,
+// for generic types such as text/xml, from its first bytes. It returns ""
+// when there is no hint.
+func likelyFeedType(contentType, feedContent string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch strings.ToLower(mediaType) {
+	case "application/rss+xml", "application/x-rss+xml":
+		return FeedTypeRSS
+	case "application/atom+xml":
+		return FeedTypeAtom
+	case "application/rdf+xml":
+		return FeedTypeRDF
+	case "application/feed+json", "application/json":
+		return FeedTypeJSON
 	}
 
-	return "", fmt.Errorf("could not extract title from feed (not valid RSS or Atom)")
+	head := strings.ToLower(feedContent[:min(len(feedContent), feedSniffLength)])
+	switch {
+	case strings.HasPrefix(head, "{"):
+		return FeedTypeJSON
+	case strings.Contains(head, "<rss"):
+		return FeedTypeRSS
+	case strings.Contains(head, "<rdf:rdf"):
+		return FeedTypeRDF
+	case strings.Contains(head, "<feed"):
+		return FeedTypeAtom
+	}
+	return ""
+}
+
+// trimFeedPrefix strips a leading UTF-8 byte order mark and whitespace, which
+// some servers send before the XML declaration or JSON Feed object
+func trimFeedPrefix(feedContent string) string {
+	return strings.TrimLeft(strings.TrimPrefix(feedContent, "\uFEFF"), " \t\r\n")
+}
+
+// decodeFeedXML unmarshals feed XML, transcoding non-UTF-8 documents to UTF-8
+// according to the charset declared in the XML prolog
+func decodeFeedXML(feedContent string, v interface{}) error {
+	decoder := xml.NewDecoder(strings.NewReader(feedContent))
+	decoder.CharsetReader = charset.NewReaderLabel
+	return decoder.Decode(v)
 }
 
 // IsSuccessful returns true if the feed discovery was successful
@@ -480,6 +1488,103 @@ func getContentPreview(content string, maxLength int) string {
 	return preview
 }
 
+// feedMediaTypes are response content types that indicate the document is a feed
+var feedMediaTypes = []string{
+	"application/rss+xml",
+	"application/atom+xml",
+	"application/rdf+xml",
+	"application/feed+json",
+	"application/x-rss+xml",
+}
+
+// isFeedContentType returns true if a Content-Type header names a feed format
+func isFeedContentType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, mediaType := range feedMediaTypes {
+		if strings.Contains(contentType, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFeedContentAnalysis returns true if analyzeContentType sniffed an RSS or Atom document
+func isFeedContentAnalysis(analysis string) bool {
+	switch analysis {
+	case "rss", "rss_xml", "atom", "atom_xml":
+		return true
+	}
+	return false
+}
+
+// describeFeedHint explains why a page was treated as a feed, for error messages
+func describeFeedHint(contentType, analysis string) string {
+	if isFeedContentType(contentType) {
+		return "content type " + contentType
+	}
+	return "content looks like " + analysis
+}
+
+// shouldRetryBlocked returns true if RetryBlocked is set, the fetched page looks
+// soft-blocked and it wasn't already fetched with BrowserUserAgent
+func shouldRetryBlocked(ctx context.Context, opts DiscoveryOptions, pageContent string) bool {
+	return opts.RetryBlocked && ctx.Err() == nil && opts.UserAgent != BrowserUserAgent && isSoftBlockPage(pageContent)
+}
+
+// retryWithBrowserUserAgent repeats discovery once with BrowserUserAgent
+func retryWithBrowserUserAgent(ctx context.Context, pageURL string, opts DiscoveryOptions) *FeedDiscoveryResult {
+	logrus.WithFields(logrus.Fields{
+		"url":        pageURL,
+		"user_agent": opts.UserAgent,
+	}).Info("Page looks like a soft-blocked error page, retrying with a browser user agent")
+
+	retryOpts := opts
+	retryOpts.UserAgent = BrowserUserAgent
+	retryOpts.RetryBlocked = false
+	result := DiscoverFeedWithOptions(ctx, pageURL, retryOpts)
+
+	if result.IsSuccessful() {
+		logrus.WithFields(logrus.Fields{
+			"url":                 pageURL,
+			"feed_url":            result.FeedURL,
+			"original_user_agent": opts.UserAgent,
+		}).Info("Retry with a browser user agent found a feed the original user agent could not")
+	} else {
+		logrus.WithFields(logrus.Fields{
+			"url":   pageURL,
+			"error": result.Error,
+		}).Debug("Retry with a browser user agent failed too")
+	}
+
+	return result
+}
+
+// softBlockTitleMarkers are phrases in the <title> of common CDN and server error pages
+var softBlockTitleMarkers = []string{"403", "forbidden", "access denied", "404", "not found", "just a moment", "attention required"}
+
+var htmlTitleRegex = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// isSoftBlockPage returns true if a page that was served successfully looks
+// like a 403 or 404 error page, judged by its content or its HTML title
+func isSoftBlockPage(content string) bool {
+	switch analyzeContentType(content) {
+	case "403_error", "404_error":
+		return true
+	case "html":
+		match := htmlTitleRegex.FindStringSubmatch(content)
+		if match == nil {
+			return false
+		}
+		title := strings.ToLower(match[1])
+		for _, marker := range softBlockTitleMarkers {
+			if strings.Contains(title, marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // analyzeContentType attempts to determine what type of content we received
 func analyzeContentType(content string) string {
 	if len(content) == 0 {
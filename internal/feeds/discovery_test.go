@@ -0,0 +1,334 @@
+package feeds
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseFeedMetadataWindows1252(t *testing.T) {
+	// "Caf\xe9 \x93News\x94" is "Café “News”" in Windows-1252
+	feed := "<?xml version=\"1.0\" encoding=\"windows-1252\"?>\n" +
+		"<rss version=\"2.0\"><channel><title>Caf\xe9 \x93News\x94</title>" +
+		"<link>https://example.com/</link></channel></rss>"
+
+	metadata, err := parseFeedMetadata(feed, "application/rss+xml")
+	if err != nil {
+		t.Fatalf("parseFeedMetadata() error = %v", err)
+	}
+	if want := "Café “News”"; metadata.Title != want {
+		t.Errorf("Title = %q, want %q", metadata.Title, want)
+	}
+	if metadata.FeedType != FeedTypeRSS {
+		t.Errorf("FeedType = %q, want %q", metadata.FeedType, FeedTypeRSS)
+	}
+}
+
+func TestDiscoverFeedRetriesRateLimitedPage(t *testing.T) {
+	var pageRequests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if pageRequests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><link rel="alternate" type="application/rss+xml" href="/feed.xml"></head></html>`))
+	})
+	mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(`<rss version="2.0"><channel><title>Example</title></channel></rss>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// The backoff alone would outlast this deadline; only the Retry-After
+	// delay lets the retry happen in time
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewHTTPClient(HTTPConfig{Timeout: 5 * time.Second, MaxRedirects: 3})
+	result := DiscoverFeedWithOptions(ctx, server.URL+"/", DiscoveryOptions{
+		HTTPClient:        client,
+		PageRetryAttempts: 1,
+		PageRetryBackoff:  RetryBackoff{Base: time.Hour, Max: time.Hour, RetryAfterMax: 5 * time.Second},
+		UserAgent:         "test-agent",
+		NoCommonPaths:     true,
+	})
+
+	if result.Error != nil {
+		t.Fatalf("DiscoverFeedWithOptions() error = %v", result.Error)
+	}
+	if want := server.URL + "/feed.xml"; result.FeedURL != want {
+		t.Errorf("FeedURL = %q, want %q", result.FeedURL, want)
+	}
+	if got := pageRequests.Load(); got != 2 {
+		t.Errorf("page requests = %d, want 2", got)
+	}
+}
+
+func TestParseFeedMetadataByteOrderMark(t *testing.T) {
+	tests := []struct {
+		name        string
+		feed        string
+		contentType string
+		wantType    string
+	}{
+		{
+			name:        "RSS with BOM before XML declaration",
+			feed:        "\uFEFF<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<rss version=\"2.0\"><channel><title>BOM Blog</title></channel></rss>",
+			contentType: "application/rss+xml",
+			wantType:    FeedTypeRSS,
+		},
+		{
+			name:        "RSS with BOM and whitespace before XML declaration",
+			feed:        "\uFEFF\r\n  <?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<rss version=\"2.0\"><channel><title>BOM Blog</title></channel></rss>",
+			contentType: "text/xml",
+			wantType:    FeedTypeRSS,
+		},
+		{
+			name:        "Atom with BOM and no content type",
+			feed:        "\uFEFF<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<feed xmlns=\"http://www.w3.org/2005/Atom\"><title>BOM Blog</title></feed>",
+			contentType: "",
+			wantType:    FeedTypeAtom,
+		},
+		{
+			name:        "JSON Feed with BOM",
+			feed:        "\uFEFF{\"version\": \"https://jsonfeed.org/version/1.1\", \"title\": \"BOM Blog\"}",
+			contentType: "application/feed+json",
+			wantType:    FeedTypeJSON,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if trimmed := trimFeedPrefix(tt.feed); !strings.HasPrefix(trimmed, "<?xml") && !strings.HasPrefix(trimmed, "{") {
+				t.Errorf("trimFeedPrefix() = %q, want the BOM and whitespace removed", trimmed[:min(len(trimmed), 20)])
+			}
+
+			metadata, err := parseFeedMetadata(tt.feed, tt.contentType)
+			if err != nil {
+				t.Fatalf("parseFeedMetadata() error = %v", err)
+			}
+			if want := "BOM Blog"; metadata.Title != want {
+				t.Errorf("Title = %q, want %q", metadata.Title, want)
+			}
+			if metadata.FeedType != tt.wantType {
+				t.Errorf("FeedType = %q, want %q", metadata.FeedType, tt.wantType)
+			}
+		})
+	}
+}
+
+// namespacedRSS declares the dc, content and atom prefixes commonly found in
+// WordPress feeds; the channel's own title is followed by an empty dc:title,
+// which the structured RSS parser would take instead
+const namespacedRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"
+	xmlns:dc="http://purl.org/dc/elements/1.1/"
+	xmlns:content="http://purl.org/rss/1.0/modules/content/"
+	xmlns:atom="http://www.w3.org/2005/Atom">
+<channel>
+	<atom:link href="https://example.com/feed/" rel="self" type="application/rss+xml"/>
+	<title><![CDATA[Namespaced & Friends]]></title>
+	<dc:title></dc:title>
+	<link>https://example.com/</link>
+	<item>
+		<title>First post</title>
+		<dc:creator>Someone</dc:creator>
+		<content:encoded><![CDATA[<p>Hello</p>]]></content:encoded>
+	</item>
+</channel>
+</rss>`
+
+func TestScanFeedMetadataNamespacedRSS(t *testing.T) {
+	metadata, ok := scanFeedMetadata(namespacedRSS)
+	if !ok {
+		t.Fatal("scanFeedMetadata() ok = false, want true")
+	}
+	if want := "Namespaced & Friends"; metadata.Title != want {
+		t.Errorf("Title = %q, want %q", metadata.Title, want)
+	}
+	if metadata.FeedType != FeedTypeRSS {
+		t.Errorf("FeedType = %q, want %q", metadata.FeedType, FeedTypeRSS)
+	}
+
+	metadata, err := parseFeedMetadata(namespacedRSS, "application/rss+xml")
+	if err != nil {
+		t.Fatalf("parseFeedMetadata() error = %v", err)
+	}
+	if want := "Namespaced & Friends"; metadata.Title != want {
+		t.Errorf("parseFeedMetadata() Title = %q, want %q", metadata.Title, want)
+	}
+	if metadata.FeedType != FeedTypeRSS {
+		t.Errorf("parseFeedMetadata() FeedType = %q, want %q", metadata.FeedType, FeedTypeRSS)
+	}
+}
+
+func TestParseRSSMetadataChannelLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		links    string
+		wantSite string
+		wantSelf string
+	}{
+		{
+			name: "self link before plain link",
+			links: `<atom:link href="https://example.com/feed/" rel="self" type="application/rss+xml"/>
+				<link>https://example.com/</link>`,
+			wantSite: "https://example.com/",
+			wantSelf: "https://example.com/feed/",
+		},
+		{
+			name: "plain link before self link",
+			links: `<link>https://example.com/</link>
+				<atom:link href="https://example.com/feed/" rel="self" type="application/rss+xml"/>`,
+			wantSite: "https://example.com/",
+			wantSelf: "https://example.com/feed/",
+		},
+		{
+			name: "hub and self links around plain link",
+			links: `<atom:link href="https://hub.example.net/" rel="hub"/>
+				<link>https://example.com/</link>
+				<atom:link href="https://example.com/feed/" rel="self"/>`,
+			wantSite: "https://example.com/",
+			wantSelf: "https://example.com/feed/",
+		},
+		{
+			name:     "only a self link",
+			links:    `<atom:link href="https://example.com/feed/" rel="self"/>`,
+			wantSite: "https://example.com/feed/",
+			wantSelf: "https://example.com/feed/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel>
+<title>Example</title>` + tt.links + `</channel></rss>`
+
+			metadata, ok := parseRSSMetadata(feed)
+			if !ok {
+				t.Fatal("parseRSSMetadata() ok = false, want true")
+			}
+			if metadata.SiteURL != tt.wantSite {
+				t.Errorf("SiteURL = %q, want %q", metadata.SiteURL, tt.wantSite)
+			}
+			if metadata.SelfURL != tt.wantSelf {
+				t.Errorf("SelfURL = %q, want %q", metadata.SelfURL, tt.wantSelf)
+			}
+		})
+	}
+}
+
+func TestDiscoverFeedUsesChannelSelfLink(t *testing.T) {
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><link rel="alternate" type="application/rss+xml" href="/rss"></head></html>`))
+	})
+	mux.HandleFunc("/rss", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(`<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel>` +
+			`<title>Example</title><link>` + server.URL + `/</link>` +
+			`<atom:link href="` + server.URL + `/feed.xml" rel="self"/></channel></rss>`))
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	result := DiscoverFeedWithOptions(context.Background(), server.URL+"/", DiscoveryOptions{
+		HTTPClient:    NewHTTPClient(HTTPConfig{Timeout: 5 * time.Second, MaxRedirects: 3}),
+		UserAgent:     "test-agent",
+		NoCommonPaths: true,
+	})
+
+	if result.Error != nil {
+		t.Fatalf("DiscoverFeedWithOptions() error = %v", result.Error)
+	}
+	if want := server.URL + "/feed.xml"; result.FeedURL != want {
+		t.Errorf("FeedURL = %q, want the self link %q", result.FeedURL, want)
+	}
+	if want := server.URL + "/rss"; result.FetchedURL != want {
+		t.Errorf("FetchedURL = %q, want %q", result.FetchedURL, want)
+	}
+}
+
+func TestWordPressRoot(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		wantRoot string
+		wantOK   bool
+	}{
+		{
+			name:     "generator meta tag",
+			html:     `<meta name="generator" content="WordPress 6.5">`,
+			wantRoot: "https://example.com",
+			wantOK:   true,
+		},
+		{
+			name:     "relative wp-content URL",
+			html:     `<link rel="stylesheet" href="/wp-content/themes/x/style.css">`,
+			wantRoot: "https://example.com",
+			wantOK:   true,
+		},
+		{
+			name:     "absolute same-host wp-json URL",
+			html:     `<link rel="https://api.w.org/" href="https://example.com/wp-json/">`,
+			wantRoot: "https://example.com",
+			wantOK:   true,
+		},
+		{
+			name:     "install in a subdirectory",
+			html:     `<script src="https://example.com/blog/wp-content/plugins/x.js"></script>`,
+			wantRoot: "https://example.com/blog",
+			wantOK:   true,
+		},
+		{
+			name:   "image hotlinked from another WordPress site",
+			html:   `<img src="https://other.example.net/wp-content/uploads/cat.jpg">`,
+			wantOK: false,
+		},
+		{
+			name:   "protocol-relative URL on another host",
+			html:   `<img src="//cdn.example.net/wp-content/uploads/cat.jpg">`,
+			wantOK: false,
+		},
+		{
+			name:   "no WordPress signs",
+			html:   `<html><head><title>Plain</title></head></html>`,
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, ok := wordPressRoot(tt.html, "https://example.com/2024/05/post/")
+			if ok != tt.wantOK || root != tt.wantRoot {
+				t.Errorf("wordPressRoot() = %q, %v, want %q, %v", root, ok, tt.wantRoot, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFindFeedLinksPrefersAdvertisedFeedOverWordPressGuess(t *testing.T) {
+	page := `<html><head>
+<link rel="alternate" type="application/rss+xml" href="https://example.com/blog/index.xml">
+<link rel="stylesheet" href="/wp-content/themes/x/style.css">
+</head></html>`
+
+	candidates := findFeedLinks(page, "https://example.com/", nil)
+	if len(candidates) == 0 {
+		t.Fatal("findFeedLinks() found no candidates")
+	}
+	if want := "https://example.com/blog/index.xml"; candidates[0].URL != want {
+		t.Errorf("first candidate = %q, want the advertised %q", candidates[0].URL, want)
+	}
+}
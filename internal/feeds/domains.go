@@ -0,0 +1,151 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lmorchard/linkding-to-opml/internal/atomicfile"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/publicsuffix"
+)
+
+// DomainStats counts the discovery results for the bookmarks on one domain
+type DomainStats struct {
+	Domain     string
+	Successful int
+	Failed     int
+}
+
+// Total returns the number of bookmarks on the domain that were processed
+func (d *DomainStats) Total() int {
+	return d.Successful + d.Failed
+}
+
+// SuccessRate returns the percentage of the domain's bookmarks that have a feed
+func (d *DomainStats) SuccessRate() float64 {
+	if d.Total() == 0 {
+		return 0
+	}
+	return float64(d.Successful) * 100 / float64(d.Total())
+}
+
+// RegistrableDomain returns the registrable domain (eTLD+1) of a URL, so that
+// blog.example.co.uk and www.example.co.uk count together, or the bare host
+// name when it has no public suffix (an IP address or localhost). A URL
+// without a host is returned unchanged.
+func RegistrableDomain(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Hostname() == "" {
+		return rawURL
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	if domain, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return domain
+	}
+	return host
+}
+
+// recordDomainResult counts a processed bookmark towards its domain's stats
+func (s *ProcessingStats) recordDomainResult(result *FeedDiscoveryResult) {
+	domain := RegistrableDomain(result.URL)
+	entry, ok := s.Domains[domain]
+	if !ok {
+		entry = &DomainStats{Domain: domain}
+		s.Domains[domain] = entry
+	}
+	if result.IsSuccessful() {
+		entry.Successful++
+	} else {
+		entry.Failed++
+	}
+}
+
+// SortedDomainStats returns the per-domain stats with the most failures
+// first, then the most bookmarks, then by domain name
+func (s *ProcessingStats) SortedDomainStats() []*DomainStats {
+	domains := make([]*DomainStats, 0, len(s.Domains))
+	for _, entry := range s.Domains {
+		domains = append(domains, entry)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		a, b := domains[i], domains[j]
+		if a.Failed != b.Failed {
+			return a.Failed > b.Failed
+		}
+		if a.Total() != b.Total() {
+			return a.Total() > b.Total()
+		}
+		return a.Domain < b.Domain
+	})
+	return domains
+}
+
+// FormatDomainStats formats the per-domain stats as an aligned table, most
+// failures first
+func (s *ProcessingStats) FormatDomainStats() string {
+	domains := s.SortedDomainStats()
+	if len(domains) == 0 {
+		return "No bookmarks were processed, so there are no per-domain stats."
+	}
+
+	width := len("Domain")
+	for _, entry := range domains {
+		width = max(width, len(entry.Domain))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Discovery results by domain:\n")
+	fmt.Fprintf(&b, "  %-*s  %9s  %6s  %6s  %7s", width, "Domain", "Bookmarks", "Feeds", "Failed", "Success")
+	for _, entry := range domains {
+		fmt.Fprintf(&b, "\n  %-*s  %9d  %6d  %6d  %6.1f%%", width, entry.Domain, entry.Total(), entry.Successful, entry.Failed, entry.SuccessRate())
+	}
+	return b.String()
+}
+
+// WriteDomainStatsFile writes the per-domain stats to a file, most failures
+// first. A .csv path gets a CSV file with a domain,bookmarks,feeds,failed
+// header; any other path gets the table printed by FormatDomainStats.
+func WriteDomainStatsFile(filePath string, stats *ProcessingStats) error {
+	var buf bytes.Buffer
+	if strings.EqualFold(filepath.Ext(filePath), ".csv") {
+		writer := csv.NewWriter(&buf)
+		_ = writer.Write([]string{"domain", "bookmarks", "feeds", "failed"})
+		for _, entry := range stats.SortedDomainStats() {
+			_ = writer.Write([]string{
+				entry.Domain,
+				strconv.Itoa(entry.Total()),
+				strconv.Itoa(entry.Successful),
+				strconv.Itoa(entry.Failed),
+			})
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return fmt.Errorf("failed to format domain stats: %w", err)
+		}
+	} else {
+		buf.WriteString(stats.FormatDomainStats())
+		buf.WriteString("\n")
+	}
+
+	if err := atomicfile.WriteFile(filePath, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write domain stats file: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"file_path": filePath,
+		"domains":   len(stats.Domains),
+	}).Debug("Wrote domain stats file")
+	return nil
+}
@@ -0,0 +1,62 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/lmorchard/linkding-to-opml/internal/atomicfile"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WriteFailuresFile writes the URL and error of each failed discovery to a
+// file for triage. A .csv path gets a CSV file with a url,error header; any
+// other path gets plain text with one tab-separated URL and error per line.
+// Failures are sorted by URL so successive files can be diffed.
+func WriteFailuresFile(filePath string, failed []*FeedDiscoveryResult) error {
+	failed = slices.Clone(failed)
+	slices.SortFunc(failed, func(a, b *FeedDiscoveryResult) int {
+		return strings.Compare(a.URL, b.URL)
+	})
+
+	var buf bytes.Buffer
+	if strings.EqualFold(filepath.Ext(filePath), ".csv") {
+		writer := csv.NewWriter(&buf)
+		_ = writer.Write([]string{"url", "error"})
+		for _, result := range failed {
+			_ = writer.Write([]string{result.URL, failureText(result)})
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return fmt.Errorf("failed to format failures: %w", err)
+		}
+	} else {
+		for _, result := range failed {
+			// Keep each failure on one line even if the error text spans several
+			errorText := strings.Join(strings.Fields(failureText(result)), " ")
+			fmt.Fprintf(&buf, "%s\t%s\n", result.URL, errorText)
+		}
+	}
+
+	if err := atomicfile.WriteFile(filePath, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write failures file: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"file_path": filePath,
+		"failures":  len(failed),
+	}).Debug("Wrote failures file")
+	return nil
+}
+
+// failureText returns the error text of a failed result
+func failureText(result *FeedDiscoveryResult) string {
+	if result.Error == nil {
+		return "unknown error"
+	}
+	return result.Error.Error()
+}
@@ -0,0 +1,78 @@
+package feeds
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// hostLimiter caps the number of simultaneous requests to each host, so many
+// workers can't all pile onto one slow site while requests to other hosts go
+// ahead in parallel. A nil hostLimiter imposes no limit.
+type hostLimiter struct {
+	mu    sync.Mutex
+	max   int
+	slots map[string]chan struct{} // keyed by lowercased hostname
+}
+
+// newHostLimiter creates a limiter allowing max requests per host, or
+// returns nil when max is zero or negative
+func newHostLimiter(max int) *hostLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &hostLimiter{
+		max:   max,
+		slots: make(map[string]chan struct{}),
+	}
+}
+
+// hostSlots returns the semaphore for host, creating it on first use
+func (l *hostLimiter) hostSlots(host string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	slots, ok := l.slots[host]
+	if !ok {
+		slots = make(chan struct{}, l.max)
+		l.slots[host] = slots
+	}
+	return slots
+}
+
+// Acquire waits until a request to host may start. It returns ctx's error if
+// ctx is cancelled first, in which case Release must not be called.
+func (l *hostLimiter) Acquire(ctx context.Context, host string) error {
+	if l == nil {
+		return nil
+	}
+
+	slots := l.hostSlots(strings.ToLower(host))
+	select {
+	case slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"host":         host,
+		"max_per_host": l.max,
+	}).Debug("Waiting for a free connection slot to host")
+
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release frees the slot taken by a successful Acquire for host
+func (l *hostLimiter) Release(host string) {
+	if l == nil {
+		return
+	}
+	<-l.hostSlots(strings.ToLower(host))
+}
@@ -2,30 +2,106 @@ package feeds
 
 import (
 	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/net/proxy"
 )
 
 // HTTPClient provides a configurable HTTP client for fetching web pages
 type HTTPClient struct {
-	client *http.Client
+	client       *http.Client
+	maxBodyBytes int64
+	headers      map[string]string
+	hostHeaders  map[string]map[string]string
+	basicAuth    map[string]BasicAuthCredentials
+	hosts        *hostLimiter
 }
 
 // HTTPConfig holds configuration for the HTTP client
 type HTTPConfig struct {
-	Timeout      time.Duration
+	Timeout      time.Duration // Whole request, including reading the body
 	UserAgent    string
 	MaxRedirects int
+	MaxBodyBytes int64       // Maximum response body size; zero or negative uses DefaultMaxBodyBytes
+	TLSConfig    *tls.Config // Optional TLS settings (custom CA, skip verify); nil uses defaults
+	Proxy        string      // Optional proxy URL (http, https, socks5); empty uses HTTP_PROXY/HTTPS_PROXY/NO_PROXY
+
+	// Phase timeouts so slow-to-respond sites are abandoned early while large
+	// bodies still get the full Timeout. Zero keeps the transport defaults.
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+
+	// Extra request headers, overriding the browser-like defaults. HostHeaders
+	// are keyed by hostname and applied after Headers for matching requests.
+	Headers     map[string]string
+	HostHeaders map[string]map[string]string
+
+	// HTTP Basic Auth credentials keyed by hostname
+	BasicAuth map[string]BasicAuthCredentials
+
+	// Maximum simultaneous requests to one host; zero or negative is unlimited
+	MaxPerHost int
+
+	hostLimiter *hostLimiter // shares MaxPerHost across clients; set by ProcessBookmarks
+}
+
+// BasicAuthCredentials holds the username and password sent to a host via
+// HTTP Basic Auth. They are only sent over https unless AllowHTTP is set,
+// since Basic Auth sends the password in the clear.
+type BasicAuthCredentials struct {
+	Username  string
+	Password  string
+	AllowHTTP bool
+}
+
+// DefaultMaxBodyBytes is the response body size limit used when none is configured
+const DefaultMaxBodyBytes = 10 * 1024 * 1024
+
+// ErrBodyTooLarge is returned when a response body exceeds the configured size limit
+var ErrBodyTooLarge = errors.New("response body exceeds size limit")
+
+// HTTPStatusError is returned by FetchPage when the server responds with a
+// status that doesn't carry a complete document (see isContentStatus)
+type HTTPStatusError struct {
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration // Delay requested by a Retry-After header, if any
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP request failed with status %d: %s", e.StatusCode, e.Status)
+}
+
+// isContentStatus reports whether a response with the status carries a
+// complete document: 200, 203 from a transforming proxy, or 226 for a delta
+// applied by the server. 204 and 205 have no body and 206 only part of one.
+func isContentStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusIMUsed:
+		return true
+	}
+	return false
 }
 
 // NewHTTPClient creates a new HTTP client with the specified configuration
 func NewHTTPClient(config HTTPConfig) *HTTPClient {
-	// Custom redirect policy to limit the number of redirects
+	h := &HTTPClient{}
+
+	// Custom redirect policy to limit the number of redirects and keep
+	// per-host headers with their host
 	redirectPolicy := func(req *http.Request, via []*http.Request) error {
 		if len(via) >= config.MaxRedirects {
 			logrus.WithFields(logrus.Fields{
@@ -35,45 +111,312 @@ func NewHTTPClient(config HTTPConfig) *HTTPClient {
 			}).Debug("HTTP request exceeded maximum redirects")
 			return fmt.Errorf("stopped after %d redirects", config.MaxRedirects)
 		}
+		h.applyRedirectHeaders(req, via[0])
 		return nil
 	}
 
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.DialTimeout > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   config.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	if config.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = config.TLSHandshakeTimeout
+	}
+	if config.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = config.ResponseHeaderTimeout
+	}
+	if config.TLSConfig != nil {
+		transport.TLSClientConfig = config.TLSConfig
+	}
+	if err := ApplyProxy(transport, config.Proxy); err != nil {
+		logrus.WithError(err).Warn("Ignoring invalid proxy configuration")
+	}
+
 	client := &http.Client{
 		Timeout:       config.Timeout,
 		CheckRedirect: redirectPolicy,
+		Transport:     transport,
+	}
+
+	maxBodyBytes := config.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = DefaultMaxBodyBytes
 	}
 
 	logrus.WithFields(logrus.Fields{
-		"timeout":       config.Timeout,
-		"user_agent":    config.UserAgent,
-		"max_redirects": config.MaxRedirects,
+		"timeout":        config.Timeout,
+		"user_agent":     config.UserAgent,
+		"max_redirects":  config.MaxRedirects,
+		"max_body_bytes": maxBodyBytes,
+		"dial_timeout":   config.DialTimeout,
+		"header_timeout": config.ResponseHeaderTimeout,
+		"custom_headers": len(config.Headers),
+		"header_hosts":   len(config.HostHeaders),
+		"basic_auth":     len(config.BasicAuth),
 	}).Debug("Created HTTP client for feed discovery")
 
-	return &HTTPClient{
-		client: client,
+	// Hostnames are matched case-insensitively
+	hostHeaders := make(map[string]map[string]string, len(config.HostHeaders))
+	for host, headers := range config.HostHeaders {
+		hostHeaders[strings.ToLower(host)] = headers
+	}
+	basicAuth := make(map[string]BasicAuthCredentials, len(config.BasicAuth))
+	for host, credentials := range config.BasicAuth {
+		basicAuth[strings.ToLower(host)] = credentials
+	}
+
+	hosts := config.hostLimiter
+	if hosts == nil {
+		hosts = newHostLimiter(config.MaxPerHost)
 	}
+
+	*h = HTTPClient{
+		hosts:        hosts,
+		client:       client,
+		maxBodyBytes: maxBodyBytes,
+		headers:      config.Headers,
+		hostHeaders:  hostHeaders,
+		basicAuth:    basicAuth,
+	}
+	return h
 }
 
-// FetchPage fetches a web page and returns its content as a string
-func (h *HTTPClient) FetchPage(url, userAgent string) (string, error) {
-	logrus.WithField("url", url).Debug("Fetching web page")
+// ApplyProxy configures transport to use the given proxy URL. HTTP(S) proxies
+// are set via Transport.Proxy; socks5:// and socks5h:// proxies replace the dialer.
+// An empty proxyURL keeps the environment-based proxy (HTTP_PROXY etc.).
+func ApplyProxy(transport *http.Transport, proxyURL string) error {
+	if proxyURL == "" {
+		transport.Proxy = http.ProxyFromEnvironment
+		return nil
+	}
 
-	// Create request
-	req, err := http.NewRequest("GET", url, nil)
+	parsed, err := url.Parse(proxyURL)
 	if err != nil {
-		return "", fmt.Errorf("failed to create HTTP request: %w", err)
+		return fmt.Errorf("invalid proxy URL: %w", err)
+	}
+
+	switch parsed.Scheme {
+	case "http", "https":
+		transport.Proxy = http.ProxyURL(parsed)
+	case "socks5", "socks5h":
+		dialer, err := proxy.FromURL(parsed, proxy.Direct)
+		if err != nil {
+			return fmt.Errorf("failed to create SOCKS proxy dialer: %w", err)
+		}
+		contextDialer, ok := dialer.(proxy.ContextDialer)
+		if !ok {
+			return fmt.Errorf("SOCKS proxy dialer does not support contexts")
+		}
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return contextDialer.DialContext(ctx, network, addr)
+		}
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q (use http, https, socks5 or socks5h)", parsed.Scheme)
+	}
+
+	// Log only the host so proxy credentials never end up in logs
+	logrus.WithFields(logrus.Fields{
+		"scheme": parsed.Scheme,
+		"host":   parsed.Host,
+	}).Debug("Configured explicit proxy for HTTP transport")
+
+	return nil
+}
+
+// applyCustomHeaders sets the configured global and per-host headers on req.
+// Header values are never logged since they often carry credentials.
+func (h *HTTPClient) applyCustomHeaders(req *http.Request) {
+	for name, value := range h.headers {
+		req.Header.Set(name, value)
+	}
+
+	hostHeaders, ok := h.hostHeaders[strings.ToLower(req.URL.Hostname())]
+	if !ok {
+		return
+	}
+	for name, value := range hostHeaders {
+		req.Header.Set(name, value)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"host":    req.URL.Hostname(),
+		"headers": len(hostHeaders),
+	}).Debug("Applied per-host request headers")
+}
+
+// applyRedirectHeaders fixes up the headers of a redirect hop. net/http
+// copies the first request's headers onto every hop, so when a hop goes to
+// another host the first host's per-host headers and Basic Auth credentials
+// are removed and the new host's are applied instead. A hop to plain http on
+// the same host loses the credentials unless AllowHTTP is set.
+func (h *HTTPClient) applyRedirectHeaders(req, first *http.Request) {
+	from := strings.ToLower(first.URL.Hostname())
+	if strings.ToLower(req.URL.Hostname()) == from {
+		if credentials, ok := h.basicAuth[from]; ok && !credentials.AllowHTTP && req.URL.Scheme != "https" {
+			// Restore an explicit Authorization header from host_headers
+			req.Header.Del("Authorization")
+			h.applyCustomHeaders(req)
+		}
+		return
+	}
+
+	for name := range h.hostHeaders[from] {
+		req.Header.Del(name)
+	}
+	if _, ok := h.basicAuth[from]; ok {
+		req.Header.Del("Authorization")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"from_host": first.URL.Hostname(),
+		"to_host":   req.URL.Hostname(),
+	}).Debug("Redirected to another host, replacing per-host headers")
+
+	// Restore global headers a removed per-host one overrode, then add the
+	// new host's
+	h.applyBasicAuth(req)
+	h.applyCustomHeaders(req)
+}
+
+// applyBasicAuth sets the Authorization header on req when Basic Auth
+// credentials are configured for its host, and req uses https or the
+// credentials allow plain http. The credentials are never logged.
+func (h *HTTPClient) applyBasicAuth(req *http.Request) {
+	credentials, ok := h.basicAuth[strings.ToLower(req.URL.Hostname())]
+	if !ok {
+		return
+	}
+	if req.URL.Scheme != "https" && !credentials.AllowHTTP {
+		logrus.WithField("host", req.URL.Hostname()).Warn("Not sending HTTP Basic Auth credentials over plain http (set allow_http to permit it)")
+		return
+	}
+	req.SetBasicAuth(credentials.Username, credentials.Password)
+
+	logrus.WithField("host", req.URL.Hostname()).Debug("Applied HTTP Basic Auth credentials")
+}
+
+// PageResponse holds the body and relevant response details of a fetched page
+type PageResponse struct {
+	Body        string
+	FinalURL    string // URL after following redirects
+	StatusCode  int
+	ContentType string
+	MaxAge      time.Duration // Cache-Control max-age, or zero if the response sets none
+	NoStore     bool          // Cache-Control no-store, no-cache or max-age=0
+}
+
+// Accept headers sent with requests. Pages get a browser's; candidate feed
+// URLs ask for a feed first, so content-negotiating servers don't answer with
+// an HTML landing page.
+const (
+	PageAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8"
+	FeedAccept = "application/rss+xml,application/atom+xml,application/feed+json,application/rdf+xml,application/xml;q=0.9,text/xml;q=0.9,*/*;q=0.8"
+)
+
+// newRequest creates a request carrying the browser-like default headers,
+// the given Accept header, and the configured credentials and headers
+func (h *HTTPClient) newRequest(ctx context.Context, method, url, userAgent, accept string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 
 	// Set User-Agent header
 	req.Header.Set("User-Agent", userAgent)
 
 	// Set additional headers that make us look more like a browser
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
+	req.Header.Set("Accept", accept)
 	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
 	req.Header.Set("Accept-Encoding", "gzip, deflate")
 	req.Header.Set("Connection", "keep-alive")
 	req.Header.Set("Upgrade-Insecure-Requests", "1")
 
+	// Apply configured credentials and headers last so they override the
+	// defaults; an explicit Authorization header wins over Basic Auth
+	h.applyBasicAuth(req)
+	h.applyCustomHeaders(req)
+
+	return req, nil
+}
+
+// Head issues a HEAD request for a candidate feed URL, with the feed Accept
+// header, and returns the response details without a body. A status without
+// content is returned as an *HTTPStatusError, as with Fetch.
+func (h *HTTPClient) Head(ctx context.Context, url, userAgent string) (*PageResponse, error) {
+	req, err := h.newRequest(ctx, http.MethodHead, url, userAgent, FeedAccept)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.hosts.Acquire(ctx, req.URL.Hostname()); err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer h.hosts.Release(req.URL.Hostname())
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	resp.Body.Close()
+
+	if !isContentStatus(resp.StatusCode) {
+		return nil, &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	maxAge, noStore := parseCacheControl(resp.Header.Get("Cache-Control"))
+	return &PageResponse{
+		FinalURL:    resp.Request.URL.String(),
+		StatusCode:  resp.StatusCode,
+		ContentType: GetContentType(resp),
+		MaxAge:      maxAge,
+		NoStore:     noStore,
+	}, nil
+}
+
+// FetchPage fetches a web page and returns its content as a string
+func (h *HTTPClient) FetchPage(url, userAgent string) (string, error) {
+	resp, err := h.Fetch(context.Background(), url, userAgent)
+	if err != nil {
+		return "", err
+	}
+	return resp.Body, nil
+}
+
+// Fetch fetches a web page and returns its content along with response details
+// such as the final URL after redirects. The request is abandoned if ctx is cancelled.
+func (h *HTTPClient) Fetch(ctx context.Context, url, userAgent string) (*PageResponse, error) {
+	return h.fetch(ctx, url, userAgent, PageAccept)
+}
+
+// FetchFeed fetches a candidate feed URL like Fetch, but with an Accept
+// header that prefers feed formats over HTML
+func (h *HTTPClient) FetchFeed(ctx context.Context, url, userAgent string) (*PageResponse, error) {
+	return h.fetch(ctx, url, userAgent, FeedAccept)
+}
+
+// fetch performs a GET request with the given Accept header
+func (h *HTTPClient) fetch(ctx context.Context, url, userAgent, accept string) (*PageResponse, error) {
+	logrus.WithField("url", url).Debug("Fetching web page")
+
+	req, err := h.newRequest(ctx, http.MethodGet, url, userAgent, accept)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hold the host's slot until the body has been read
+	if err := h.hosts.Acquire(ctx, req.URL.Hostname()); err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer h.hosts.Release(req.URL.Hostname())
+
 	// Perform request
 	resp, err := h.client.Do(req)
 	if err != nil {
@@ -81,18 +424,22 @@ func (h *HTTPClient) FetchPage(url, userAgent string) (string, error) {
 			"url":   url,
 			"error": err,
 		}).Debug("HTTP request failed")
-		return "", fmt.Errorf("HTTP request failed: %w", err)
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Check status code
-	if resp.StatusCode != http.StatusOK {
+	// Empty (204, 205) and partial (206) responses aren't usable documents
+	if !isContentStatus(resp.StatusCode) {
 		logrus.WithFields(logrus.Fields{
 			"url":         url,
 			"status_code": resp.StatusCode,
 			"status":      resp.Status,
-		}).Debug("HTTP request returned non-200 status")
-		return "", fmt.Errorf("HTTP request failed with status %d: %s", resp.StatusCode, resp.Status)
+		}).Debug("HTTP request returned a status without content")
+		return nil, &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
 
 	// Handle compressed content
@@ -102,7 +449,7 @@ func (h *HTTPClient) FetchPage(url, userAgent string) (string, error) {
 	if strings.Contains(contentEncoding, "gzip") {
 		gzipReader, err := gzip.NewReader(resp.Body)
 		if err != nil {
-			return "", fmt.Errorf("failed to create gzip reader: %w", err)
+			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
 		}
 		defer gzipReader.Close()
 		reader = gzipReader
@@ -110,10 +457,17 @@ func (h *HTTPClient) FetchPage(url, userAgent string) (string, error) {
 		logrus.WithField("url", url).Debug("Decompressing gzip content")
 	}
 
-	// Read response body
-	body, err := io.ReadAll(reader)
+	// Read response body, allowing one byte past the limit to detect oversized bodies
+	body, err := io.ReadAll(io.LimitReader(reader, h.maxBodyBytes+1))
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if int64(len(body)) > h.maxBodyBytes {
+		logrus.WithFields(logrus.Fields{
+			"url":            url,
+			"max_body_bytes": h.maxBodyBytes,
+		}).Debug("HTTP response body exceeded size limit")
+		return nil, fmt.Errorf("%w of %d bytes", ErrBodyTooLarge, h.maxBodyBytes)
 	}
 
 	logrus.WithFields(logrus.Fields{
@@ -125,13 +479,126 @@ func (h *HTTPClient) FetchPage(url, userAgent string) (string, error) {
 		"was_compressed":   strings.Contains(contentEncoding, "gzip"),
 	}).Debug("Successfully fetched web page")
 
-	return string(body), nil
+	finalURL := resp.Request.URL.String()
+	if finalURL != url {
+		logrus.WithFields(logrus.Fields{
+			"url":       url,
+			"final_url": finalURL,
+		}).Debug("Request was redirected")
+	}
+
+	maxAge, noStore := parseCacheControl(resp.Header.Get("Cache-Control"))
+	return &PageResponse{
+		Body:        string(body),
+		FinalURL:    finalURL,
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		MaxAge:      maxAge,
+		NoStore:     noStore,
+	}, nil
+}
+
+// parseRetryAfter returns the delay requested by a Retry-After header, given
+// either as a number of seconds or as an HTTP date. Missing, malformed and
+// past values yield zero.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
+
+// parseCacheControl returns the max-age directive of a Cache-Control header
+// value, or zero if it has none, and whether the response asks not to be
+// reused without checking again: no-store, no-cache or max-age=0
+func parseCacheControl(value string) (maxAge time.Duration, noStore bool) {
+	for _, directive := range strings.Split(value, ",") {
+		name, arg, found := strings.Cut(strings.TrimSpace(directive), "=")
+		switch {
+		case strings.EqualFold(name, "no-store"), strings.EqualFold(name, "no-cache"):
+			noStore = true
+		case found && strings.EqualFold(name, "max-age") && maxAge == 0:
+			seconds, err := strconv.Atoi(strings.Trim(arg, `"`))
+			if err != nil || seconds < 0 {
+				continue
+			}
+			if seconds == 0 {
+				noStore = true
+				continue
+			}
+			// RFC 9111 caps delta-seconds at 2^31, which also avoids overflow
+			maxAge = time.Duration(min(seconds, 1<<31)) * time.Second
+		}
+	}
+	if noStore {
+		return 0, true
+	}
+	return maxAge, false
 }
 
 // IsRetryableError determines if an HTTP error is worth retrying
 func IsRetryableError(err error) bool {
-	// For now, we don't implement retry logic
-	// This could be expanded to handle specific error types like temporary network errors
+	if err == nil {
+		return false
+	}
+
+	// Server-side and rate-limit statuses may succeed on a later attempt
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+
+	// Network timeouts are usually transient
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return false
+}
+
+// IsFatalError determines if an error points at a problem with this machine's
+// network setup rather than with the site being fetched, meaning every other
+// fetch is bound to fail the same way: an unreachable or rejecting proxy, no
+// network route, or a DNS resolver that isn't answering. "No such host" and
+// HTTP errors from the site itself are per-bookmark failures.
+func IsFatalError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusProxyAuthRequired
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "proxyconnect" {
+		return true
+	}
+
+	if errors.Is(err, syscall.ENETUNREACH) {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound && !dnsErr.IsTimeout
+	}
+
 	return false
 }
 
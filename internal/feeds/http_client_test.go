@@ -0,0 +1,243 @@
+package feeds
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchRejectsOversizedBody(t *testing.T) {
+	const maxBodyBytes = 1024
+	const streamLimit = 64 << 20
+
+	// Stream far more than the limit; a client that buffered the whole body
+	// would read all of it
+	var written atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		chunk := []byte(strings.Repeat("x", 32<<10))
+		for written.Load() < streamLimit {
+			n, err := w.Write(chunk)
+			written.Add(int64(n))
+			if err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(HTTPConfig{
+		Timeout:      10 * time.Second,
+		MaxRedirects: 5,
+		MaxBodyBytes: maxBodyBytes,
+	})
+
+	_, err := client.Fetch(context.Background(), server.URL, "test-agent")
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("Fetch() error = %v, want ErrBodyTooLarge", err)
+	}
+
+	// Closing the server waits for the handler, which stops once the client
+	// has hung up
+	server.Close()
+	if n := written.Load(); n >= streamLimit {
+		t.Errorf("server wrote the whole %d byte body; the client should stop reading at the limit", n)
+	}
+}
+
+func TestRedirectDropsPerHostHeadersOnHostChange(t *testing.T) {
+	var gotKey, gotGlobal string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.Header.Get("X-Api-Key")
+		gotGlobal = r.Header.Get("X-Global")
+		w.Header().Set("Content-Type", "text/html")
+	}))
+	defer target.Close()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer origin.Close()
+
+	// Reach the origin as localhost so the redirect to 127.0.0.1 changes host
+	originURL := strings.Replace(origin.URL, "127.0.0.1", "localhost", 1)
+	client := NewHTTPClient(HTTPConfig{
+		Timeout:      10 * time.Second,
+		MaxRedirects: 5,
+		Headers:      map[string]string{"X-Global": "everywhere"},
+		HostHeaders:  map[string]map[string]string{"localhost": {"X-Api-Key": "secret"}},
+	})
+
+	if _, err := client.Fetch(context.Background(), originURL, "test-agent"); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if gotKey != "" {
+		t.Errorf("redirect target received X-Api-Key %q meant for the origin host", gotKey)
+	}
+	if gotGlobal != "everywhere" {
+		t.Errorf("redirect target X-Global = %q, want the global header", gotGlobal)
+	}
+}
+
+func TestBasicAuthSentOnlyToConfiguredHost(t *testing.T) {
+	authorizations := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations <- r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	// The same server is reached as two hosts: localhost has credentials,
+	// 127.0.0.1 doesn't
+	port := server.URL[strings.LastIndex(server.URL, ":"):]
+	client := NewHTTPClient(HTTPConfig{
+		Timeout:      5 * time.Second,
+		MaxRedirects: 3,
+		BasicAuth: map[string]BasicAuthCredentials{
+			"localhost": {Username: "reader", Password: "secret", AllowHTTP: true},
+		},
+	})
+
+	if _, err := client.Fetch(context.Background(), "http://localhost"+port+"/", "test-agent"); err != nil {
+		t.Fatalf("Fetch(localhost) error = %v", err)
+	}
+	// base64 of "reader:secret"
+	if got, want := <-authorizations, "Basic cmVhZGVyOnNlY3JldA=="; got != want {
+		t.Errorf("configured host got Authorization %q, want %q", got, want)
+	}
+
+	if _, err := client.Fetch(context.Background(), "http://127.0.0.1"+port+"/", "test-agent"); err != nil {
+		t.Fatalf("Fetch(127.0.0.1) error = %v", err)
+	}
+	if got := <-authorizations; got != "" {
+		t.Errorf("other host got Authorization %q, want none", got)
+	}
+}
+
+func TestBasicAuthSentOnlyOverHTTPS(t *testing.T) {
+	authorizations := make(chan string, 4)
+	record := func(w http.ResponseWriter, r *http.Request) {
+		authorizations <- r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	}
+	plain := httptest.NewServer(http.HandlerFunc(record))
+	defer plain.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/downgrade" {
+			http.Redirect(w, r, plain.URL+"/", http.StatusFound)
+			return
+		}
+		record(w, r)
+	}))
+	defer secure.Close()
+
+	// Both servers listen on 127.0.0.1, so the redirect stays on the same host
+	newClient := func(allowHTTP bool) *HTTPClient {
+		return NewHTTPClient(HTTPConfig{
+			Timeout:      5 * time.Second,
+			MaxRedirects: 3,
+			TLSConfig:    secure.Client().Transport.(*http.Transport).TLSClientConfig,
+			BasicAuth: map[string]BasicAuthCredentials{
+				"127.0.0.1": {Username: "reader", Password: "secret", AllowHTTP: allowHTTP},
+			},
+		})
+	}
+	// base64 of "reader:secret"
+	const credentials = "Basic cmVhZGVyOnNlY3JldA=="
+
+	tests := []struct {
+		name      string
+		allowHTTP bool
+		url       string
+		want      string
+	}{
+		{"https", false, secure.URL + "/", credentials},
+		{"plain http", false, plain.URL + "/", ""},
+		{"redirect from https to http", false, secure.URL + "/downgrade", ""},
+		{"plain http allowed", true, plain.URL + "/", credentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newClient(tt.allowHTTP).Fetch(context.Background(), tt.url, "test-agent"); err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if got := <-authorizations; got != tt.want {
+				t.Errorf("Authorization = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchRequiresCompleteContentStatus(t *testing.T) {
+	tests := []struct {
+		status  int
+		wantErr bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusNonAuthoritativeInfo, false},
+		{http.StatusNoContent, true},
+		{http.StatusResetContent, true},
+		{http.StatusPartialContent, true},
+		{http.StatusNotFound, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.WriteHeader(tt.status)
+				if tt.status != http.StatusNoContent && tt.status != http.StatusResetContent {
+					w.Write([]byte("<html></html>"))
+				}
+			}))
+			defer server.Close()
+
+			client := NewHTTPClient(HTTPConfig{Timeout: 5 * time.Second, MaxRedirects: 3})
+			_, err := client.Fetch(context.Background(), server.URL, "test-agent")
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("Fetch() error = %v, want success", err)
+				}
+				return
+			}
+
+			var statusErr *HTTPStatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.status {
+				t.Errorf("Fetch() error = %v, want an HTTPStatusError with status %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestParseCacheControl(t *testing.T) {
+	tests := []struct {
+		value       string
+		wantMaxAge  time.Duration
+		wantNoStore bool
+	}{
+		{"", 0, false},
+		{"public, max-age=3600", time.Hour, false},
+		{`max-age="60"`, time.Minute, false},
+		{"max-age=99999999999", (1 << 31) * time.Second, false},
+		{"max-age=bogus", 0, false},
+		{"max-age=0", 0, true},
+		{"no-store", 0, true},
+		{"max-age=3600, no-cache", 0, true},
+		{"private, No-Store, max-age=3600", 0, true},
+	}
+
+	for _, tt := range tests {
+		maxAge, noStore := parseCacheControl(tt.value)
+		if maxAge != tt.wantMaxAge || noStore != tt.wantNoStore {
+			t.Errorf("parseCacheControl(%q) = %v, %v; want %v, %v", tt.value, maxAge, noStore, tt.wantMaxAge, tt.wantNoStore)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package feeds
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// KnownFeeds is a set of feed URLs that are already known elsewhere, such as
+// feeds saved as Linkding bookmarks, so that an export can leave them out.
+// URLs are compared after light normalization: the scheme and host are
+// lowercased and any fragment and trailing slash are ignored.
+type KnownFeeds map[string]bool
+
+// Add records a feed URL as known
+func (k KnownFeeds) Add(feedURL string) {
+	if key := knownFeedKey(feedURL); key != "" {
+		k[key] = true
+	}
+}
+
+// Contains reports whether a feed URL is known
+func (k KnownFeeds) Contains(feedURL string) bool {
+	key := knownFeedKey(feedURL)
+	return key != "" && k[key]
+}
+
+// knownFeedKey normalizes a feed URL for comparison
+func knownFeedKey(feedURL string) string {
+	feedURL = strings.TrimSpace(feedURL)
+	parsed, err := url.Parse(feedURL)
+	if err != nil || parsed.Host == "" {
+		return strings.TrimSuffix(feedURL, "/")
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	return strings.TrimSuffix(parsed.String(), "/")
+}
+
+// DropKnownFeeds removes the results whose feed URL is known, returning the
+// remaining results and the number dropped
+func DropKnownFeeds(results []*FeedDiscoveryResult, known KnownFeeds) ([]*FeedDiscoveryResult, int) {
+	kept := make([]*FeedDiscoveryResult, 0, len(results))
+	for _, result := range results {
+		if known.Contains(result.FeedURL) {
+			logrus.WithFields(logrus.Fields{
+				"url":      result.URL,
+				"feed_url": result.FeedURL,
+			}).Debug("Feed already known, leaving it out of the export")
+			continue
+		}
+		kept = append(kept, result)
+	}
+	return kept, len(results) - len(kept)
+}
@@ -0,0 +1,48 @@
+package feeds
+
+import "testing"
+
+func TestKnownFeedKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		feedURL string
+		want    string
+	}{
+		{"unchanged", "https://example.com/feed.xml", "https://example.com/feed.xml"},
+		{"scheme and host case", "HTTPS://Example.COM/feed.xml", "https://example.com/feed.xml"},
+		{"path case kept", "https://example.com/Feed.xml", "https://example.com/Feed.xml"},
+		{"fragment", "https://example.com/feed.xml#latest", "https://example.com/feed.xml"},
+		{"trailing slash", "https://example.com/feed/", "https://example.com/feed"},
+		{"root with trailing slash", "https://example.com/", "https://example.com"},
+		{"fragment after trailing slash", "https://example.com/feed/#top", "https://example.com/feed"},
+		{"query kept", "https://example.com/?feed=rss2", "https://example.com/?feed=rss2"},
+		{"surrounding whitespace", "  https://example.com/feed/\n", "https://example.com/feed"},
+		{"not absolute", "feed.xml/", "feed.xml"},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := knownFeedKey(tt.feedURL); got != tt.want {
+				t.Errorf("knownFeedKey(%q) = %q, want %q", tt.feedURL, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKnownFeedsMatchNormalizedURLs(t *testing.T) {
+	known := make(KnownFeeds)
+	known.Add("https://Example.com/feed/")
+	known.Add("")
+
+	for _, feedURL := range []string{"https://example.com/feed", "HTTPS://EXAMPLE.COM/feed/#comments"} {
+		if !known.Contains(feedURL) {
+			t.Errorf("Contains(%q) = false, want true", feedURL)
+		}
+	}
+	for _, feedURL := range []string{"https://example.com/Feed", "https://example.com/feed?page=2", ""} {
+		if known.Contains(feedURL) {
+			t.Errorf("Contains(%q) = true, want false", feedURL)
+		}
+	}
+}
@@ -1,25 +1,82 @@
 package feeds
 
 import (
+	"context"
 	"fmt"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
-	"linkding-to-opml/internal/cache"
-	"linkding-to-opml/internal/linkding"
+	"github.com/lmorchard/linkding-to-opml/internal/cache"
+	"github.com/lmorchard/linkding-to-opml/internal/linkding"
+	"github.com/lmorchard/linkding-to-opml/internal/stats"
 
 	"github.com/sirupsen/logrus"
 )
 
+// progressLogInterval is how often progress is logged when not in verbose mode
+const progressLogInterval = 10 * time.Second
+
+// DefaultMinCacheTTL is the shortest time a result is cached for when its
+// response asks for less, or forbids caching, and no minimum is configured
+const DefaultMinCacheTTL = time.Hour
+
 // ProcessingConfig holds configuration for bookmark processing
 type ProcessingConfig struct {
 	Concurrency    int
 	MaxAge         int
+	AuthMaxAge     int           // Max age in hours for cached auth-required results
+	MinTTL         time.Duration // Shortest Cache-Control TTL honored; zero uses DefaultMinCacheTTL
+	MaxTTL         time.Duration // Longest Cache-Control TTL honored; zero, or more than MaxAge, uses MaxAge
+	NoCache        bool
+	VerifyCache    bool // Re-fetch the feed of each cached hit and rediscover if it no longer works
 	UserAgent      string
 	HTTPConfig     HTTPConfig
+	FeedHTTPConfig HTTPConfig
+	PageRetries    int
+	PageBackoff    RetryBackoff
+	FeedRetries    int
+	FeedBackoff    RetryBackoff
 	Verbose        bool
 	SaveFailedHTML bool
 	DebugOutputDir string
+
+	CommonFeedPaths []string // Paths probed as a last resort during discovery
+	NoCommonPaths   bool     // Disable common path probing entirely
+
+	// TitleFallbacks name where, in order, to take a title for a feed that
+	// has none (see DiscoveryOptions.TitleFallbacks)
+	TitleFallbacks []string
+
+	// UserAgents, when set, are rotated across discoveries instead of always
+	// sending UserAgent
+	UserAgents []string
+
+	// AdaptiveConcurrency tunes the number of in-flight discoveries between 1
+	// and Concurrency based on latency and transient errors
+	AdaptiveConcurrency bool
+
+	// ValidateFeeds checks newly discovered feeds for common problems; cached
+	// results carry no warnings
+	ValidateFeeds bool
+
+	// FailFast stops processing at the first error that would make every
+	// other discovery fail too (see IsFatalError)
+	FailFast bool
+
+	// RetryBlocked repeats discovery once with BrowserUserAgent when a page
+	// without feed links looks like a 403/404 error page served with a 200
+	RetryBlocked bool
+
+	// OnFeed, when set, is called with each feed as soon as it is discovered,
+	// after deduplication. Calls come from a single goroutine.
+	OnFeed func(*FeedDiscoveryResult)
+
+	SkipList      *cache.SkipList // URLs known to have no feed, never probed
+	AddSkipOnFail bool            // Add URLs whose page has no feed links to SkipList
+
+	userAgents *userAgentPool // built from UserAgents by ProcessBookmarks
 }
 
 // ProcessingStats holds statistics about the processing operation
@@ -29,42 +86,94 @@ type ProcessingStats struct {
 	NewDiscoveries    int
 	SuccessfulFeeds   int
 	FailedDiscoveries int
+	AuthRequired      int
+	DuplicateURLs     int
+	DuplicateFeeds    int
+	KnownFeeds        int // Feeds left out because they were already known (see DropKnownFeeds)
+	StaleFeeds        int
+	DeadCachedFeeds   int // VerifyCache only: cached feeds that failed verification and were rediscovered
+	FeedsWithWarnings int
+	Skipped           int
+	SkippedScheme     int
+	Processed         int  // Bookmarks that finished processing (less than total if interrupted)
+	Interrupted       bool // Processing was cancelled before all bookmarks were handled
+	FinalConcurrency  int  // Adaptive mode only: concurrency limit when processing finished
+	PeakConcurrency   int  // Adaptive mode only: highest concurrency limit reached
+	StartTime         time.Time
+	EndTime           time.Time
 	ProcessingTime    time.Duration
+	FatalError        error // FailFast only: the error that stopped processing
+
+	Domains map[string]*DomainStats // Results of the processed bookmarks by registrable domain
 }
 
-// ProcessBookmarks processes bookmarks concurrently to discover feeds
-func ProcessBookmarks(bookmarks []*linkding.Bookmark, cache *cache.Cache, config ProcessingConfig) ([]*FeedDiscoveryResult, *ProcessingStats) {
+// ProcessBookmarks processes bookmarks concurrently to discover feeds, returning
+// the successful results (deduplicated by feed URL) and the failed ones separately.
+// When ctx is cancelled (interrupt or deadline) no new bookmarks are started,
+// in-flight requests are abandoned and their bookmarks dropped, and the
+// partial results are returned with stats.Interrupted set.
+func ProcessBookmarks(ctx context.Context, bookmarks []*linkding.Bookmark, cache cache.Cache, config ProcessingConfig) ([]*FeedDiscoveryResult, []*FeedDiscoveryResult, *ProcessingStats) {
 	startTime := time.Now()
 
 	stats := &ProcessingStats{
 		TotalBookmarks: len(bookmarks),
+		StartTime:      startTime,
+		Domains:        make(map[string]*DomainStats),
 	}
 
+	// Lets a fatal error under FailFast stop the workers
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Collapse bookmarks sharing a URL so each page is only fetched once
+	bookmarks, stats.DuplicateURLs = dedupeBookmarks(bookmarks)
+
 	logrus.WithFields(logrus.Fields{
 		"total_bookmarks": len(bookmarks),
 		"concurrency":     config.Concurrency,
 		"max_age_hours":   config.MaxAge,
 	}).Info("Starting concurrent bookmark processing")
 
-	// Create HTTP client for feed discovery
+	// Shared by all workers, since each gets its own copy of config
+	config.userAgents = newUserAgentPool(config.UserAgents)
+
+	// Create HTTP clients for page fetching and candidate feed fetching. Both
+	// count towards the same per-host cap.
+	hosts := newHostLimiter(config.HTTPConfig.MaxPerHost)
+	config.HTTPConfig.hostLimiter = hosts
+	config.FeedHTTPConfig.hostLimiter = hosts
 	httpClient := NewHTTPClient(config.HTTPConfig)
+	feedClient := NewHTTPClient(config.FeedHTTPConfig)
 
 	// Create channels for work distribution
 	bookmarkChan := make(chan *linkding.Bookmark, len(bookmarks))
 	resultChan := make(chan *FeedDiscoveryResult, len(bookmarks))
 
+	// In adaptive mode the pool is sized for the maximum and the limiter
+	// decides how many workers may run a discovery at once
+	var limiter *adaptiveLimiter
+	if config.AdaptiveConcurrency {
+		limiter = newAdaptiveLimiter(config.Concurrency, config.HTTPConfig.Timeout/2)
+	}
+
 	// Start worker goroutines
 	var wg sync.WaitGroup
 	for i := 0; i < config.Concurrency; i++ {
 		wg.Add(1)
-		go worker(i+1, bookmarkChan, resultChan, cache, httpClient, config, stats, &wg)
+		go worker(ctx, i+1, bookmarkChan, resultChan, cache, httpClient, feedClient, limiter, config, &wg)
 	}
 
-	// Send bookmarks to workers
-	for _, bookmark := range bookmarks {
-		bookmarkChan <- bookmark
-	}
-	close(bookmarkChan)
+	// Send bookmarks to workers, stopping early if cancelled
+	go func() {
+		defer close(bookmarkChan)
+		for _, bookmark := range bookmarks {
+			select {
+			case bookmarkChan <- bookmark:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
 	// Wait for all workers to complete
 	go func() {
@@ -73,16 +182,20 @@ func ProcessBookmarks(bookmarks []*linkding.Bookmark, cache *cache.Cache, config
 	}()
 
 	// Collect results
-	var successful []*FeedDiscoveryResult
+	var successful, failed []*FeedDiscoveryResult
+	seenFeeds := make(map[string]bool)
 
 	processedCount := 0
+	lastProgressLog := time.Now()
 	for result := range resultChan {
 		processedCount++
+		eta := remainingTime(startTime, processedCount, len(bookmarks))
 
 		if config.Verbose {
 			if result.IsSuccessful() {
 				logrus.WithFields(logrus.Fields{
 					"progress": fmt.Sprintf("%d/%d", processedCount, len(bookmarks)),
+					"eta":      eta,
 					"url":      result.URL,
 					"feed":     result.FeedURL,
 					"title":    result.FeedTitle,
@@ -90,17 +203,89 @@ func ProcessBookmarks(bookmarks []*linkding.Bookmark, cache *cache.Cache, config
 			} else {
 				logrus.WithFields(logrus.Fields{
 					"progress": fmt.Sprintf("%d/%d", processedCount, len(bookmarks)),
+					"eta":      eta,
 					"url":      result.URL,
 					"error":    result.Error,
 				}).Warn("Failed to discover feed")
 			}
+		} else if time.Since(lastProgressLog) >= progressLogInterval && processedCount < len(bookmarks) {
+			lastProgressLog = time.Now()
+			logrus.WithFields(logrus.Fields{
+				"progress": fmt.Sprintf("%d/%d", processedCount, len(bookmarks)),
+				"eta":      eta,
+			}).Info("Discovery progress")
+		}
+
+		if config.FailFast && stats.FatalError == nil && IsFatalError(result.Error) {
+			stats.FatalError = fmt.Errorf("%s: %w", result.URL, result.Error)
+			logrus.WithError(result.Error).WithField("url", result.URL).Error("Stopping on fatal error (--fail-fast)")
+			cancel()
+		}
+
+		if result.cacheHit {
+			stats.CacheHits++
+		}
+		if result.newDiscovery {
+			stats.NewDiscoveries++
+		}
+		if result.deadCachedFeed {
+			stats.DeadCachedFeeds++
+		}
+
+		if result.IsSkipped() {
+			stats.Skipped++
+			continue
 		}
+		if result.IsUnsupportedScheme() {
+			stats.SkippedScheme++
+			continue
+		}
+		stats.recordDomainResult(result)
 
 		if result.IsSuccessful() {
-			successful = append(successful, result)
 			stats.SuccessfulFeeds++
+
+			// Several bookmarks on the same site often resolve to the same feed
+			if seenFeeds[result.FeedURL] {
+				stats.DuplicateFeeds++
+				logrus.WithFields(logrus.Fields{
+					"url":  result.URL,
+					"feed": result.FeedURL,
+				}).Debug("Skipping duplicate feed URL")
+				continue
+			}
+			seenFeeds[result.FeedURL] = true
+
+			if len(result.Warnings) > 0 {
+				stats.FeedsWithWarnings++
+				for _, warning := range result.Warnings {
+					logrus.WithFields(logrus.Fields{
+						"feed": result.FeedURL,
+						"code": warning.Code,
+					}).Info("Feed validation warning: " + warning.Message)
+				}
+			}
+
+			if result.IsStale(time.Now()) {
+				stats.StaleFeeds++
+				logrus.WithFields(logrus.Fields{
+					"url":          result.URL,
+					"feed":         result.FeedURL,
+					"item_count":   result.ItemCount,
+					"last_updated": result.LastUpdated,
+				}).Info("Feed looks inactive")
+			}
+
+			successful = append(successful, result)
+			if config.OnFeed != nil {
+				config.OnFeed(result)
+			}
 		} else {
+			failed = append(failed, result)
 			stats.FailedDiscoveries++
+			if result.IsAuthRequired() {
+				stats.AuthRequired++
+			}
 		}
 	}
 
@@ -111,44 +296,119 @@ func ProcessBookmarks(bookmarks []*linkding.Bookmark, cache *cache.Cache, config
 		logrus.Debug("Successfully saved updated cache")
 	}
 
-	stats.ProcessingTime = time.Since(startTime)
+	stats.Processed = processedCount
+	stats.Interrupted = ctx.Err() != nil && processedCount < len(bookmarks)
+	if stats.Interrupted {
+		logrus.WithFields(logrus.Fields{
+			"processed": processedCount,
+			"total":     len(bookmarks),
+		}).Warn("Processing interrupted, keeping partial results")
+	}
+
+	stats.EndTime = time.Now()
+	stats.ProcessingTime = stats.EndTime.Sub(startTime)
+	stats.FinalConcurrency, stats.PeakConcurrency = limiter.Limits()
 
 	logrus.WithFields(logrus.Fields{
 		"total_processed":    processedCount,
 		"successful_feeds":   stats.SuccessfulFeeds,
 		"failed_discoveries": stats.FailedDiscoveries,
+		"auth_required":      stats.AuthRequired,
 		"cache_hits":         stats.CacheHits,
 		"new_discoveries":    stats.NewDiscoveries,
+		"duplicate_urls":     stats.DuplicateURLs,
+		"duplicate_feeds":    stats.DuplicateFeeds,
+		"stale_feeds":        stats.StaleFeeds,
+		"skipped":            stats.Skipped,
 		"processing_time":    stats.ProcessingTime,
 	}).Info("Completed bookmark processing")
 
-	return successful, stats
+	return successful, failed, stats
+}
+
+// dedupeBookmarks removes bookmarks whose URL has already been seen, keeping
+// the first occurrence, and returns the number of duplicates collapsed
+func dedupeBookmarks(bookmarks []*linkding.Bookmark) ([]*linkding.Bookmark, int) {
+	seen := make(map[string]bool, len(bookmarks))
+	unique := make([]*linkding.Bookmark, 0, len(bookmarks))
+
+	for _, bookmark := range bookmarks {
+		if seen[bookmark.URL] {
+			logrus.WithField("url", bookmark.URL).Debug("Skipping duplicate bookmark URL")
+			continue
+		}
+		seen[bookmark.URL] = true
+		unique = append(unique, bookmark)
+	}
+
+	return unique, len(bookmarks) - len(unique)
 }
 
 // worker processes bookmarks in a separate goroutine
-func worker(workerID int, bookmarkChan <-chan *linkding.Bookmark, resultChan chan<- *FeedDiscoveryResult,
-	cache *cache.Cache, httpClient *HTTPClient, config ProcessingConfig, stats *ProcessingStats, wg *sync.WaitGroup,
+func worker(ctx context.Context, workerID int, bookmarkChan <-chan *linkding.Bookmark, resultChan chan<- *FeedDiscoveryResult,
+	cache cache.Cache, httpClient, feedClient *HTTPClient, limiter *adaptiveLimiter, config ProcessingConfig, wg *sync.WaitGroup,
 ) {
 	defer wg.Done()
 
 	logrus.WithField("worker_id", workerID).Debug("Worker started")
 
 	for bookmark := range bookmarkChan {
-		result := processBookmark(bookmark, cache, httpClient, config, stats)
+		// Leave queued bookmarks alone once cancelled
+		if ctx.Err() != nil {
+			break
+		}
+		result := processBookmark(ctx, bookmark, cache, httpClient, feedClient, limiter, config)
+		if result == nil {
+			continue
+		}
+		result.Tags = bookmark.Tags
 		resultChan <- result
 	}
 
 	logrus.WithField("worker_id", workerID).Debug("Worker finished")
 }
 
-// processBookmark processes a single bookmark, checking cache first
-func processBookmark(bookmark *linkding.Bookmark, cache *cache.Cache, httpClient *HTTPClient,
-	config ProcessingConfig, stats *ProcessingStats,
+// processBookmark processes a single bookmark, checking cache first. It returns
+// nil if the discovery was cut short by ctx, so the half-finished attempt is
+// neither reported nor cached.
+func processBookmark(ctx context.Context, bookmark *linkding.Bookmark, resultCache cache.Cache, httpClient, feedClient *HTTPClient,
+	limiter *adaptiveLimiter, config ProcessingConfig,
 ) *FeedDiscoveryResult {
-	// Check cache first
-	if cachedEntry := cache.Get(bookmark.URL, config.MaxAge); cachedEntry != nil {
-		stats.CacheHits++
+	// Only web pages can carry feed links; ftp:, file:, javascript: and the like
+	// would just fail confusingly (and be retried)
+	if scheme, ok := unsupportedScheme(bookmark.URL); ok {
+		logrus.WithFields(logrus.Fields{
+			"url":    bookmark.URL,
+			"scheme": scheme,
+		}).Debug("Skipping bookmark with non-HTTP URL")
+		return &FeedDiscoveryResult{
+			URL:   bookmark.URL,
+			Error: fmt.Errorf("%w %q", ErrUnsupportedScheme, scheme),
+		}
+	}
 
+	// Bookmarks the user has marked as feedless are never fetched
+	if config.SkipList.Contains(bookmark.URL) {
+		logrus.WithField("url", bookmark.URL).Debug("Skipping bookmark on skip list")
+		return &FeedDiscoveryResult{
+			URL:   bookmark.URL,
+			Error: ErrSkipped,
+		}
+	}
+
+	// Check cache first, unless a fresh discovery was requested
+	cachedEntry := lookupCache(bookmark.URL, resultCache, config)
+	deadCachedFeed := false
+	if cachedEntry != nil && cachedEntry.HasFeed() && config.VerifyCache {
+		if !verifyCachedFeed(ctx, cachedEntry, httpClient, feedClient, limiter, config) {
+			if ctx.Err() != nil {
+				return nil
+			}
+			deadCachedFeed = true
+			cachedEntry = nil
+		}
+	}
+	if cachedEntry != nil {
 		logrus.WithFields(logrus.Fields{
 			"url": bookmark.URL,
 			"age": time.Since(cachedEntry.Timestamp),
@@ -158,10 +418,22 @@ func processBookmark(bookmark *linkding.Bookmark, cache *cache.Cache, httpClient
 			URL:       bookmark.URL,
 			FeedURL:   cachedEntry.FeedURL,
 			FeedTitle: cachedEntry.FeedTitle,
+			Language:  cachedEntry.Language,
+			FeedType:  cachedEntry.FeedType,
+			IconURL:   cachedEntry.IconURL,
+			HubURL:    cachedEntry.HubURL,
+
+			ItemCount:     cachedEntry.ItemCount,
+			LastUpdated:   cachedEntry.LastUpdated,
+			ActivityKnown: cachedEntry.ActivityKnown,
+
+			cacheHit: true,
 		}
 
 		// Set error if this was a failed cache entry
-		if !cachedEntry.HasFeed() {
+		if cachedEntry.AuthRequired {
+			result.Error = fmt.Errorf("%w (cached)", ErrAuthRequired)
+		} else if !cachedEntry.HasFeed() {
 			result.Error = fmt.Errorf("no feed found (cached)")
 		}
 
@@ -169,28 +441,232 @@ func processBookmark(bookmark *linkding.Bookmark, cache *cache.Cache, httpClient
 	}
 
 	// Perform new discovery
-	stats.NewDiscoveries++
-
 	logrus.WithField("url", bookmark.URL).Debug("Performing new feed discovery")
 
-	result := DiscoverFeedWithDebug(bookmark.URL, httpClient, config.UserAgent, config.SaveFailedHTML, config.DebugOutputDir)
+	limiter.Acquire()
+	discoveryStart := time.Now()
+	// The page and its candidate feeds are fetched with the same user agent
+	userAgent := config.userAgents.Next(config.UserAgent)
+	if config.userAgents != nil {
+		logrus.WithFields(logrus.Fields{
+			"url":        bookmark.URL,
+			"user_agent": userAgent,
+		}).Debug("Selected rotating user agent for discovery")
+	}
+
+	result := DiscoverFeedWithOptions(ctx, bookmark.URL, DiscoveryOptions{
+		HTTPClient:        httpClient,
+		FeedClient:        feedClient,
+		PageRetryAttempts: config.PageRetries,
+		PageRetryBackoff:  config.PageBackoff,
+		FeedRetryAttempts: config.FeedRetries,
+		FeedRetryBackoff:  config.FeedBackoff,
+		UserAgent:         userAgent,
+		SaveFailedHTML:    config.SaveFailedHTML,
+		DebugOutputDir:    config.DebugOutputDir,
+		CommonPaths:       config.CommonFeedPaths,
+		ValidateFeed:      config.ValidateFeeds,
+		RetryBlocked:      config.RetryBlocked,
+		NoCommonPaths:     config.NoCommonPaths,
+		TitleFallbacks:    config.TitleFallbacks,
+		BookmarkTitle:     bookmark.Title,
+	})
+	limiter.Release(time.Since(discoveryStart), IsRetryableError(result.Error))
+
+	if !result.IsSuccessful() && ctx.Err() != nil {
+		logrus.WithField("url", bookmark.URL).Debug("Discarding discovery cut short by cancellation")
+		return nil
+	}
+	result.newDiscovery = true
+	result.deadCachedFeed = deadCachedFeed
 
 	// Update cache with result
 	if result.IsSuccessful() {
-		cache.Set(bookmark.URL, result.FeedURL, result.FeedTitle)
+		resultCache.Put(&cache.CacheEntry{
+			URL:       bookmark.URL,
+			FeedURL:   result.FeedURL,
+			FeedTitle: result.FeedTitle,
+			Language:  result.Language,
+			FeedType:  result.FeedType,
+			IconURL:   result.IconURL,
+			HubURL:    result.HubURL,
+
+			ItemCount:     result.ItemCount,
+			LastUpdated:   result.LastUpdated,
+			ActivityKnown: result.ActivityKnown,
+
+			TTLSeconds: int(cacheTTL(result, config) / time.Second),
+		})
+	} else if result.IsAuthRequired() {
+		resultCache.SetAuthRequired(bookmark.URL)
 	} else {
-		cache.SetFailed(bookmark.URL)
+		if result.IsPageGone() {
+			// Runs within the cache max age of each other count as one
+			resultCache.SetGone(bookmark.URL, time.Duration(config.MaxAge)*time.Hour)
+		} else {
+			resultCache.SetFailed(bookmark.URL)
+		}
+
+		// Only pages that loaded without any feed links go on the skip list,
+		// which is permanent; unreachable, gone or rejecting sites may recover
+		if config.AddSkipOnFail && config.SkipList != nil && result.IsNoFeedLinks() {
+			if err := config.SkipList.Add(bookmark.URL); err != nil {
+				logrus.WithError(err).WithField("url", bookmark.URL).Warn("Failed to add URL to skip list")
+			}
+		}
 	}
 
 	return result
 }
 
+// verifyCachedFeed re-fetches the feed URL of a cached result and reports
+// whether it still serves a feed. Only the feed is fetched, not the page.
+func verifyCachedFeed(ctx context.Context, entry *cache.CacheEntry, httpClient, feedClient *HTTPClient, limiter *adaptiveLimiter, config ProcessingConfig) bool {
+	limiter.Acquire()
+	verifyStart := time.Now()
+	err := verifyFeed(ctx, entry.FeedURL, DiscoveryOptions{
+		HTTPClient:        httpClient,
+		FeedClient:        feedClient,
+		FeedRetryAttempts: config.FeedRetries,
+		FeedRetryBackoff:  config.FeedBackoff,
+		UserAgent:         config.userAgents.Next(config.UserAgent),
+	})
+	limiter.Release(time.Since(verifyStart), IsRetryableError(err))
+
+	if err != nil {
+		if ctx.Err() == nil {
+			logrus.WithFields(logrus.Fields{
+				"url":   entry.URL,
+				"feed":  entry.FeedURL,
+				"error": err,
+			}).Info("Cached feed no longer works, rediscovering")
+		}
+		return false
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"url":  entry.URL,
+		"feed": entry.FeedURL,
+	}).Debug("Verified cached feed")
+	return true
+}
+
+// unsupportedScheme returns the scheme of a bookmark URL and true if it is
+// anything other than http or https. Unparseable URLs are left to fail in discovery.
+// cacheTTL returns how long to cache a successful result: the TTL its
+// response asked for, kept between MinTTL and MaxTTL so a hostile or careless
+// header can neither pin a result for years nor force rediscovery on every
+// run. Zero means the response asked for nothing and MaxAge applies.
+func cacheTTL(result *FeedDiscoveryResult, config ProcessingConfig) time.Duration {
+	if result.CacheTTL <= 0 && !result.CacheNoStore {
+		return 0
+	}
+
+	minTTL := config.MinTTL
+	if minTTL <= 0 {
+		minTTL = DefaultMinCacheTTL
+	}
+	maxTTL := time.Duration(config.MaxAge) * time.Hour
+	if config.MaxTTL > 0 {
+		maxTTL = min(maxTTL, config.MaxTTL)
+	}
+
+	ttl := max(result.CacheTTL, minTTL)
+	if ttl > maxTTL {
+		// Still at least a second, since a zero TTL means MaxAge
+		ttl = max(maxTTL, time.Second)
+	}
+	return ttl
+}
+
+func unsupportedScheme(bookmarkURL string) (string, bool) {
+	parsed, err := url.Parse(bookmarkURL)
+	if err != nil {
+		return "", false
+	}
+
+	scheme := strings.ToLower(parsed.Scheme)
+	return scheme, scheme != "http" && scheme != "https"
+}
+
+// lookupCache returns a fresh cache entry for the URL, or nil when there is
+// none or the cache has been bypassed with NoCache
+func lookupCache(url string, cache cache.Cache, config ProcessingConfig) *cache.CacheEntry {
+	if config.NoCache {
+		logrus.WithField("url", url).Debug("Cache bypassed, performing fresh discovery")
+		return nil
+	}
+
+	entry := cache.Get(url, config.MaxAge)
+
+	// Auth-required results expire sooner, since access may have been granted since
+	if entry != nil && entry.AuthRequired && time.Since(entry.Timestamp) > time.Duration(config.AuthMaxAge)*time.Hour {
+		logrus.WithField("url", url).Debug("Cached auth-required result expired, retrying discovery")
+		return nil
+	}
+
+	return entry
+}
+
+// remainingTime formats the estimated time left to process total bookmarks,
+// extrapolated from the time taken for the first processed ones
+func remainingTime(startTime time.Time, processed, total int) string {
+	eta := stats.EstimateRemaining(time.Since(startTime), int64(processed), int64(total))
+	if eta <= 0 {
+		return "unknown"
+	}
+	return stats.FormatDuration(eta)
+}
+
 // FormatProcessingSummary creates a user-friendly summary of processing results
 func (s *ProcessingStats) FormatProcessingSummary(quiet bool) string {
 	if quiet {
 		return ""
 	}
 
-	return fmt.Sprintf("Found %d feeds from %d bookmarks, %d cached, %d newly discovered, %d failed (Processing time: %v)",
-		s.SuccessfulFeeds, s.TotalBookmarks, s.CacheHits, s.NewDiscoveries, s.FailedDiscoveries, s.ProcessingTime.Round(time.Second))
+	summary := fmt.Sprintf("Found %d feeds from %d bookmarks, %d cached, %d newly discovered, %d failed (Processing time: %s)",
+		s.SuccessfulFeeds, s.TotalBookmarks, s.CacheHits, s.NewDiscoveries, s.FailedDiscoveries, stats.FormatDuration(s.ProcessingTime))
+	summary += "\n" + stats.FormatTimeRange(s.StartTime, s.EndTime)
+
+	if s.AuthRequired > 0 {
+		summary += fmt.Sprintf("\n%d bookmarks require authentication (HTTP 401/403)", s.AuthRequired)
+	}
+
+	if s.DuplicateURLs > 0 || s.DuplicateFeeds > 0 {
+		summary += fmt.Sprintf("\nCollapsed %d duplicate bookmark URLs and %d duplicate feeds", s.DuplicateURLs, s.DuplicateFeeds)
+	}
+
+	if s.KnownFeeds > 0 {
+		summary += fmt.Sprintf("\nLeft out %d feeds that are already bookmarked or in the existing OPML", s.KnownFeeds)
+	}
+
+	if s.Interrupted {
+		summary += fmt.Sprintf("\nInterrupted after %d of %d bookmarks; results are partial", s.Processed, s.TotalBookmarks-s.DuplicateURLs)
+	}
+
+	if s.PeakConcurrency > 0 {
+		summary += fmt.Sprintf("\nAdaptive concurrency finished at %d (peak %d)", s.FinalConcurrency, s.PeakConcurrency)
+	}
+
+	if s.Skipped > 0 {
+		summary += fmt.Sprintf("\nSkipped %d bookmarks listed in the skip list", s.Skipped)
+	}
+
+	if s.SkippedScheme > 0 {
+		summary += fmt.Sprintf("\nSkipped %d bookmarks with non-HTTP URLs (ftp:, file:, javascript:, etc.)", s.SkippedScheme)
+	}
+
+	if s.DeadCachedFeeds > 0 {
+		summary += fmt.Sprintf("\n%d cached feeds failed verification and were rediscovered", s.DeadCachedFeeds)
+	}
+
+	if s.StaleFeeds > 0 {
+		summary += fmt.Sprintf("\n%d feeds look inactive (no items, or nothing new in over two years)", s.StaleFeeds)
+	}
+
+	if s.FeedsWithWarnings > 0 {
+		summary += fmt.Sprintf("\n%d feeds have validation warnings", s.FeedsWithWarnings)
+	}
+
+	return summary
 }
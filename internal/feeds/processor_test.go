@@ -0,0 +1,145 @@
+package feeds
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lmorchard/linkding-to-opml/internal/cache"
+	"github.com/lmorchard/linkding-to-opml/internal/linkding"
+)
+
+func TestProcessBookmarksCountsCacheHitsAndDiscoveries(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><head><link rel="alternate" type="application/rss+xml" href="/feed%s.xml"></head></html>`, r.URL.Path)
+	})
+	mux.HandleFunc("/feed/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprintf(w, `<rss version="2.0"><channel><title>Feed %s</title></channel></rss>`, r.URL.Path)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	const bookmarkCount = 8
+	var bookmarks []*linkding.Bookmark
+	for i := range bookmarkCount {
+		bookmarks = append(bookmarks, &linkding.Bookmark{ID: i, URL: fmt.Sprintf("%s/page%d", server.URL, i)})
+	}
+
+	httpConfig := HTTPConfig{Timeout: 10 * time.Second, MaxRedirects: 5}
+	processingConfig := ProcessingConfig{
+		Concurrency:    4,
+		MaxAge:         24,
+		UserAgent:      "test-agent",
+		HTTPConfig:     httpConfig,
+		FeedHTTPConfig: httpConfig,
+		NoCommonPaths:  true,
+	}
+	resultCache := cache.NewCache(filepath.Join(t.TempDir(), "cache.gob"))
+
+	successful, _, stats := ProcessBookmarks(context.Background(), bookmarks, resultCache, processingConfig)
+	if len(successful) != bookmarkCount {
+		t.Fatalf("first run found %d feeds, want %d", len(successful), bookmarkCount)
+	}
+	if stats.NewDiscoveries != bookmarkCount || stats.CacheHits != 0 {
+		t.Errorf("first run NewDiscoveries = %d, CacheHits = %d, want %d and 0", stats.NewDiscoveries, stats.CacheHits, bookmarkCount)
+	}
+
+	// Cached feeds that no longer verify are rediscovered and counted as dead
+	processingConfig.VerifyCache = true
+	mux.HandleFunc("/feed/page0.xml", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+
+	_, _, stats = ProcessBookmarks(context.Background(), bookmarks, resultCache, processingConfig)
+	if stats.CacheHits != bookmarkCount-1 || stats.NewDiscoveries != 1 || stats.DeadCachedFeeds != 1 {
+		t.Errorf("second run CacheHits = %d, NewDiscoveries = %d, DeadCachedFeeds = %d, want %d, 1 and 1",
+			stats.CacheHits, stats.NewDiscoveries, stats.DeadCachedFeeds, bookmarkCount-1)
+	}
+}
+
+func TestProcessBookmarksSkipListsOnlyPagesWithoutFeedLinks(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/no-feed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>No feed</title></head></html>`)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	})
+	mux.HandleFunc("/private", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+	mux.HandleFunc("/broken-feed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><link rel="alternate" type="application/rss+xml" href="/missing.xml"></head></html>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var bookmarks []*linkding.Bookmark
+	for i, path := range []string{"/no-feed", "/gone", "/private", "/broken-feed"} {
+		bookmarks = append(bookmarks, &linkding.Bookmark{ID: i, URL: server.URL + path})
+	}
+
+	skipList, err := cache.LoadSkipList(filepath.Join(t.TempDir(), "skip.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpConfig := HTTPConfig{Timeout: 10 * time.Second, MaxRedirects: 5}
+	processingConfig := ProcessingConfig{
+		Concurrency:    2,
+		NoCache:        true,
+		UserAgent:      "test-agent",
+		HTTPConfig:     httpConfig,
+		FeedHTTPConfig: httpConfig,
+		NoCommonPaths:  true,
+		SkipList:       skipList,
+		AddSkipOnFail:  true,
+	}
+	resultCache := cache.NewCache(filepath.Join(t.TempDir(), "cache.gob"))
+
+	ProcessBookmarks(context.Background(), bookmarks, resultCache, processingConfig)
+
+	for _, bookmark := range bookmarks {
+		want := bookmark.URL == server.URL+"/no-feed"
+		if got := skipList.Contains(bookmark.URL); got != want {
+			t.Errorf("skip list contains %s = %v, want %v", bookmark.URL, got, want)
+		}
+	}
+}
+
+func TestCacheTTLStaysWithinBounds(t *testing.T) {
+	config := ProcessingConfig{MaxAge: 720, MinTTL: 6 * time.Hour, MaxTTL: 7 * 24 * time.Hour}
+
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		noStore bool
+		config  ProcessingConfig
+		want    time.Duration
+	}{
+		{"no Cache-Control", 0, false, config, 0},
+		{"within bounds", 12 * time.Hour, false, config, 12 * time.Hour},
+		{"below min_ttl", time.Minute, false, config, 6 * time.Hour},
+		{"above max_ttl", 68 * 365 * 24 * time.Hour, false, config, 7 * 24 * time.Hour},
+		{"no-store", 0, true, config, 6 * time.Hour},
+		{"above max_age without max_ttl", 90 * 24 * time.Hour, false, ProcessingConfig{MaxAge: 720}, 720 * time.Hour},
+		{"default min_ttl", time.Second, false, ProcessingConfig{MaxAge: 720}, DefaultMinCacheTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &FeedDiscoveryResult{CacheTTL: tt.ttl, CacheNoStore: tt.noStore}
+			if got := cacheTTL(result, tt.config); got != tt.want {
+				t.Errorf("cacheTTL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package feeds
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Sources a feed candidate can be discovered from
+const (
+	CandidateLink       = "link"
+	CandidateRegex      = "regex"
+	CandidateAnchor     = "anchor"
+	CandidateCommonPath = "common_path"
+	CandidateWordPress  = "wordpress"
+)
+
+// FeedCandidate is a potential feed URL found on a page, along with where it
+// came from and the title advertised for it, if any
+type FeedCandidate struct {
+	URL    string
+	Title  string
+	Source string
+}
+
+// candidateSourceScores favors feeds the page explicitly advertises over
+// ones found by guessing. WordPress's canonical /feed/ ranks just below
+// <link> autodiscovery, so it only wins over advertised comment or category
+// feeds. Feed links in the page body rank below both but above guessed paths.
+var candidateSourceScores = map[string]int{
+	CandidateLink:       30,
+	CandidateWordPress:  25,
+	CandidateRegex:      20,
+	CandidateAnchor:     10,
+	CandidateCommonPath: 0,
+}
+
+// RankFeedCandidates orders candidates so the site's main feed comes first.
+// Comment feeds and category or tag feeds are pushed down, advertised
+// rel="alternate" links are preferred over guessed paths, and shorter paths
+// win ties. Candidates with equal scores keep their discovery order.
+func RankFeedCandidates(candidates []FeedCandidate) []FeedCandidate {
+	ranked := make([]FeedCandidate, len(candidates))
+	copy(ranked, candidates)
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scoreFeedCandidate(ranked[i]) > scoreFeedCandidate(ranked[j])
+	})
+
+	return ranked
+}
+
+// scoreFeedCandidate estimates how likely a candidate is to be the main feed
+func scoreFeedCandidate(candidate FeedCandidate) int {
+	score := candidateSourceScores[candidate.Source]
+
+	path := strings.ToLower(candidate.URL)
+	if parsed, err := url.Parse(candidate.URL); err == nil {
+		path = strings.ToLower(parsed.Path)
+	}
+
+	if strings.Contains(path, "comments") {
+		score -= 40
+	}
+	if strings.Contains(path, "/category/") || strings.Contains(path, "/tag/") {
+		score -= 20
+	}
+	if strings.Contains(strings.ToLower(candidate.Title), "comments") {
+		score -= 30
+	}
+
+	// Deeper paths tend to be narrower feeds
+	score -= 2 * len(strings.FieldsFunc(path, func(r rune) bool { return r == '/' }))
+
+	return score
+}
+
+// ScoreFeed estimates how likely a discovered feed is to be its site's main
+// feed, using the same URL and title heuristics as RankFeedCandidates
+func ScoreFeed(feedURL, title string) int {
+	return scoreFeedCandidate(FeedCandidate{URL: feedURL, Title: title})
+}
+
+// candidatesFrom wraps discovered URLs as candidates from a single source
+func candidatesFrom(urls []string, source string) []FeedCandidate {
+	candidates := make([]FeedCandidate, 0, len(urls))
+	for _, u := range urls {
+		candidates = append(candidates, FeedCandidate{URL: u, Source: source})
+	}
+	return candidates
+}
+
+// candidateURLs returns the URLs of the candidates, for logging
+func candidateURLs(candidates []FeedCandidate) []string {
+	urls := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		urls[i] = candidate.URL
+	}
+	return urls
+}
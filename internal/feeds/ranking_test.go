@@ -0,0 +1,92 @@
+package feeds
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRankFeedCandidates(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []FeedCandidate
+		want       []string
+	}{
+		{
+			name: "main feed before comment feed",
+			candidates: []FeedCandidate{
+				{URL: "https://example.com/comments/feed/", Source: CandidateLink},
+				{URL: "https://example.com/feed/", Source: CandidateLink},
+			},
+			want: []string{"https://example.com/feed/", "https://example.com/comments/feed/"},
+		},
+		{
+			name: "comment feed recognized by title",
+			candidates: []FeedCandidate{
+				{URL: "https://example.com/feed/1", Title: "Comments on: Hello", Source: CandidateLink},
+				{URL: "https://example.com/feed/2", Title: "Example Blog", Source: CandidateLink},
+			},
+			want: []string{"https://example.com/feed/2", "https://example.com/feed/1"},
+		},
+		{
+			name: "main feed before category and tag feeds",
+			candidates: []FeedCandidate{
+				{URL: "https://example.com/category/go/feed", Source: CandidateLink},
+				{URL: "https://example.com/tag/news/feed", Source: CandidateLink},
+				{URL: "https://example.com/feed", Source: CandidateLink},
+			},
+			want: []string{
+				"https://example.com/feed",
+				"https://example.com/category/go/feed",
+				"https://example.com/tag/news/feed",
+			},
+		},
+		{
+			name: "advertised link before guessed path",
+			candidates: []FeedCandidate{
+				{URL: "https://example.com/rss.xml", Source: CandidateCommonPath},
+				{URL: "https://example.com/blog/index.xml", Source: CandidateLink},
+			},
+			want: []string{"https://example.com/blog/index.xml", "https://example.com/rss.xml"},
+		},
+		{
+			name: "equal scores keep discovery order",
+			candidates: []FeedCandidate{
+				{URL: "https://example.com/atom.xml", Source: CandidateLink},
+				{URL: "https://example.com/rss.xml", Source: CandidateLink},
+			},
+			want: []string{"https://example.com/atom.xml", "https://example.com/rss.xml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := candidateURLs(RankFeedCandidates(tt.candidates))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("RankFeedCandidates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreFeed(t *testing.T) {
+	main := ScoreFeed("https://example.com/feed", "Example Blog")
+
+	tests := []struct {
+		name    string
+		feedURL string
+		title   string
+	}{
+		{"comment feed", "https://example.com/comments/feed", "Example Blog"},
+		{"comment feed by title", "https://example.com/feed/c", "Comments for Example Blog"},
+		{"category feed", "https://example.com/category/go/feed", "Example Blog » Go"},
+		{"tag feed", "https://example.com/tag/news/feed", "Example Blog » News"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScoreFeed(tt.feedURL, tt.title); got >= main {
+				t.Errorf("ScoreFeed(%q) = %d, want below the main feed's %d", tt.feedURL, got, main)
+			}
+		})
+	}
+}
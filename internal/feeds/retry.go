@@ -0,0 +1,125 @@
+package feeds
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultRetryBaseBackoff is the delay before the first retry when none is configured
+	DefaultRetryBaseBackoff = time.Second
+
+	// DefaultRetryMaxBackoff caps the exponential backoff when no ceiling is configured
+	DefaultRetryMaxBackoff = 30 * time.Second
+
+	// DefaultRetryAfterMax caps the delay honored from a Retry-After header when
+	// no ceiling is configured
+	DefaultRetryAfterMax = 2 * time.Minute
+
+	// retryJitter is the fraction by which each backoff is randomly shortened or lengthened
+	retryJitter = 0.25
+)
+
+// RetryBackoff controls the delay between retry attempts. The delay doubles
+// from Base on each attempt up to Max, then varies by ±25% so that requests
+// failing together don't retry in lockstep. A Retry-After header on a 429 or
+// 5xx response replaces the backoff, up to RetryAfterMax. Zero values use the
+// defaults.
+type RetryBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+
+	RetryAfterMax time.Duration
+}
+
+// delay returns the jittered backoff before the given retry (1 for the first retry)
+func (b RetryBackoff) delay(retry int) time.Duration {
+	base := b.Base
+	if base <= 0 {
+		base = DefaultRetryBaseBackoff
+	}
+	ceiling := b.Max
+	if ceiling <= 0 {
+		ceiling = DefaultRetryMaxBackoff
+	}
+
+	backoff := ceiling
+	// Stop doubling once past the ceiling, which also avoids overflow. A
+	// retry below 1 gets the first retry's delay.
+	if shift := max(retry-1, 0); shift < 32 && base<<shift < ceiling && base<<shift > 0 {
+		backoff = base << shift
+	}
+
+	factor := 1 - retryJitter + rand.Float64()*2*retryJitter
+	return time.Duration(float64(backoff) * factor)
+}
+
+// retryAfter returns the delay a server requested via Retry-After for err, or
+// zero if it requested none. ok is false when the request is longer than
+// RetryAfterMax: a retry sooner than the server asked for would only be
+// refused again, so the caller should give up instead.
+func (b RetryBackoff) retryAfter(err error) (delay time.Duration, ok bool) {
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.RetryAfter <= 0 {
+		return 0, true
+	}
+
+	ceiling := b.RetryAfterMax
+	if ceiling <= 0 {
+		ceiling = DefaultRetryAfterMax
+	}
+	if statusErr.RetryAfter > ceiling {
+		return 0, false
+	}
+	return statusErr.RetryAfter, true
+}
+
+// retryOperation runs operation up to retries+1 times, backing off between
+// attempts, and stops early on success, on an error that isn't retryable, on
+// a Retry-After longer than the backoff allows, or when ctx is cancelled
+// during a backoff
+func retryOperation(ctx context.Context, retries int, backoff RetryBackoff, description string, operation func() error) error {
+	var err error
+
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			delay := backoff.delay(attempt)
+			retryAfter, ok := backoff.retryAfter(err)
+			if !ok {
+				logrus.WithFields(logrus.Fields{
+					"operation": description,
+					"error":     err,
+				}).Debug("Not retrying: Retry-After is longer than retry_after_max")
+				return err
+			}
+			if retryAfter > 0 {
+				delay = retryAfter
+			}
+			logrus.WithFields(logrus.Fields{
+				"operation": description,
+				"attempt":   attempt + 1,
+				"backoff":   delay,
+				"error":     err,
+			}).Debug("Retrying operation after backoff")
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			}
+		}
+
+		err = operation()
+		if err == nil || !IsRetryableError(err) {
+			return err
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,93 @@
+package feeds
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoffDelay(t *testing.T) {
+	backoff := RetryBackoff{Base: time.Second, Max: 30 * time.Second}
+
+	tests := []struct {
+		retry int
+		want  time.Duration // before jitter
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 16 * time.Second},
+		{6, 30 * time.Second},
+		{7, 30 * time.Second},
+		{40, 30 * time.Second},
+		{100, 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		low := time.Duration(float64(tt.want) * (1 - retryJitter))
+		high := time.Duration(float64(tt.want) * (1 + retryJitter))
+		// Jitter is random, so sample each retry several times
+		for range 50 {
+			if got := backoff.delay(tt.retry); got < low || got > high {
+				t.Errorf("delay(%d) = %v, want between %v and %v", tt.retry, got, low, high)
+				break
+			}
+		}
+	}
+}
+
+func TestRetryBackoffDelayDefaults(t *testing.T) {
+	var backoff RetryBackoff
+
+	low := time.Duration(float64(DefaultRetryBaseBackoff) * (1 - retryJitter))
+	high := time.Duration(float64(DefaultRetryBaseBackoff) * (1 + retryJitter))
+	if got := backoff.delay(1); got < low || got > high {
+		t.Errorf("delay(1) = %v, want between %v and %v", got, low, high)
+	}
+
+	ceiling := time.Duration(float64(DefaultRetryMaxBackoff) * (1 + retryJitter))
+	if got := backoff.delay(50); got > ceiling {
+		t.Errorf("delay(50) = %v, want at most %v", got, ceiling)
+	}
+}
+
+func TestRetryOperationRetryAfter(t *testing.T) {
+	backoff := RetryBackoff{Base: time.Hour, Max: time.Hour, RetryAfterMax: time.Second}
+
+	tests := []struct {
+		name       string
+		retryAfter time.Duration
+		wantCalls  int
+	}{
+		{"within the cap", 10 * time.Millisecond, 2},
+		{"beyond the cap", time.Hour, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rateLimited := &HTTPStatusError{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests", RetryAfter: tt.retryAfter}
+			calls := 0
+			// Only the Retry-After delay is short enough to finish before the deadline
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			err := retryOperation(ctx, 1, backoff, "test", func() error {
+				calls++
+				if calls == 1 {
+					return rateLimited
+				}
+				return nil
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if wantErr := tt.wantCalls == 1; wantErr != errors.Is(err, rateLimited) {
+				t.Errorf("retryOperation() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package feeds
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Sources an untitled feed's title can be taken from, tried in the order
+// given by DiscoveryOptions.TitleFallbacks
+const (
+	TitleFallbackBookmark = "bookmark" // the Linkding bookmark's title
+	TitleFallbackPage     = "page"     // the bookmarked page's <title>
+	TitleFallbackDomain   = "domain"   // the bookmarked page's host name, without www.
+)
+
+// ErrNoFeedTitle indicates a feed has no title and none of the configured
+// fallbacks provided one
+var ErrNoFeedTitle = errors.New("feed has no title")
+
+// ensureFeedTitle fills in the title of a feed that has none from the
+// configured fallbacks. pageContent is the bookmarked page's HTML, or empty
+// when the bookmark points straight at the feed. It returns ErrNoFeedTitle if
+// the feed is still without a title.
+func (opts DiscoveryOptions) ensureFeedTitle(metadata *feedMetadata, pageURL, pageContent string) error {
+	if metadata.Title != "" {
+		return nil
+	}
+
+	for _, source := range opts.TitleFallbacks {
+		var title string
+		switch source {
+		case TitleFallbackBookmark:
+			title = strings.TrimSpace(opts.BookmarkTitle)
+		case TitleFallbackPage:
+			title = pageTitle(pageContent)
+		case TitleFallbackDomain:
+			title = siteDomain(pageURL)
+		}
+		if title == "" {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"url":    pageURL,
+			"title":  title,
+			"source": source,
+		}).Info("Feed has no title, using fallback title")
+		metadata.Title = title
+		return nil
+	}
+
+	return ErrNoFeedTitle
+}
+
+// pageTitle returns the text of an HTML document's <title>, or "" if it has none
+func pageTitle(htmlContent string) string {
+	if htmlContent == "" {
+		return ""
+	}
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return ""
+	}
+
+	var find func(*html.Node) string
+	find = func(n *html.Node) string {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Title {
+			return strings.Join(strings.Fields(nodeText(n)), " ")
+		}
+		// Titles inside inline SVG name the image, not the page
+		if n.Type == html.ElementNode && n.DataAtom == atom.Svg {
+			return ""
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if title := find(c); title != "" {
+				return title
+			}
+		}
+		return ""
+	}
+	return find(doc)
+}
+
+// siteDomain returns the host name of a URL without any leading www.
+func siteDomain(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
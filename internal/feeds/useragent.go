@@ -0,0 +1,34 @@
+package feeds
+
+import "sync/atomic"
+
+// userAgentPool hands out user agents round-robin so consecutive discoveries
+// present different User-Agent strings
+type userAgentPool struct {
+	agents []string
+	next   atomic.Uint64
+}
+
+// newUserAgentPool creates a pool from the given user agents, or returns nil
+// if the list is empty
+func newUserAgentPool(agents []string) *userAgentPool {
+	var filtered []string
+	for _, agent := range agents {
+		if agent != "" {
+			filtered = append(filtered, agent)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil
+	}
+	return &userAgentPool{agents: filtered}
+}
+
+// Next returns the next user agent in the rotation, or fallback for a nil pool
+func (p *userAgentPool) Next(fallback string) string {
+	if p == nil {
+		return fallback
+	}
+	n := p.next.Add(1) - 1
+	return p.agents[n%uint64(len(p.agents))]
+}
@@ -0,0 +1,56 @@
+package feeds
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lmorchard/linkding-to-opml/internal/cache"
+	"github.com/lmorchard/linkding-to-opml/internal/linkding"
+)
+
+func TestProcessBookmarksRotatesUserAgents(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.Header.Get("User-Agent")]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head><title>No feed</title></head></html>")
+	}))
+	defer server.Close()
+
+	var bookmarks []*linkding.Bookmark
+	for i := range 6 {
+		bookmarks = append(bookmarks, &linkding.Bookmark{ID: i, URL: fmt.Sprintf("%s/page/%d", server.URL, i)})
+	}
+
+	httpConfig := HTTPConfig{Timeout: 10 * time.Second, MaxRedirects: 5}
+	processingConfig := ProcessingConfig{
+		Concurrency:    2,
+		NoCache:        true,
+		UserAgent:      "default-agent",
+		UserAgents:     []string{"agent-one", "agent-two", "agent-three"},
+		HTTPConfig:     httpConfig,
+		FeedHTTPConfig: httpConfig,
+		NoCommonPaths:  true,
+	}
+	resultCache := cache.NewCache(filepath.Join(t.TempDir(), "cache.gob"))
+
+	ProcessBookmarks(context.Background(), bookmarks, resultCache, processingConfig)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) < 2 {
+		t.Errorf("requests used user agents %v, want more than one", seen)
+	}
+	if seen["default-agent"] > 0 {
+		t.Errorf("requests used the default user agent while rotating: %v", seen)
+	}
+}
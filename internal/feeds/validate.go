@@ -0,0 +1,295 @@
+package feeds
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Feed validation warning codes
+const (
+	WarningUnparseable  = "unparseable"
+	WarningMissingLink  = "missing_link"
+	WarningNoItems      = "no_items"
+	WarningInvalidDate  = "invalid_date"
+	WarningRelativeURL  = "relative_url"
+	WarningMissingGUID  = "missing_guid"
+	WarningMissingTitle = "missing_title"
+)
+
+// FeedWarning describes a problem with a feed that doesn't stop it being
+// exported but may trip up stricter feed readers
+type FeedWarning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// String formats the warning for display
+func (w FeedWarning) String() string {
+	return w.Message
+}
+
+// feedCheck is a format-neutral view of a feed used for validation
+type feedCheck struct {
+	format  string
+	title   string
+	link    string
+	dates   []string
+	items   []itemCheck
+	idLabel string // what the format calls an item's unique ID, or empty if it has none
+}
+
+// itemCheck is a format-neutral view of a single feed item
+type itemCheck struct {
+	link  string
+	id    string
+	dates []string
+}
+
+// ValidateFeed checks a feed document for common problems beyond whether it
+// parses: a missing site link, no items, unparseable dates, relative item
+// links and items without a unique ID. It returns no warnings for a clean feed.
+func ValidateFeed(content string) []FeedWarning {
+	check, ok := buildFeedCheck(content)
+	if !ok {
+		return []FeedWarning{{
+			Code:    WarningUnparseable,
+			Message: "feed is not valid RSS, Atom, RDF or JSON Feed",
+		}}
+	}
+
+	var warnings []FeedWarning
+	warn := func(code, format string, args ...interface{}) {
+		warnings = append(warnings, FeedWarning{Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if check.title == "" {
+		warn(WarningMissingTitle, "%s feed has no title", check.format)
+	}
+	if check.link == "" {
+		warn(WarningMissingLink, "%s feed has no link to its website", check.format)
+	}
+	if len(check.items) == 0 {
+		warn(WarningNoItems, "%s feed has no items", check.format)
+	}
+
+	dates := check.dates
+	var relativeLinks []string
+	missingIDs := 0
+	for _, item := range check.items {
+		dates = append(dates, item.dates...)
+		if item.link != "" && !isAbsoluteURL(item.link) {
+			relativeLinks = append(relativeLinks, item.link)
+		}
+		if item.id == "" {
+			missingIDs++
+		}
+	}
+
+	var invalidDates []string
+	for _, date := range dates {
+		if strings.TrimSpace(date) == "" {
+			continue
+		}
+		if _, ok := parseFeedDate(date); !ok {
+			invalidDates = append(invalidDates, strings.TrimSpace(date))
+		}
+	}
+
+	if len(invalidDates) > 0 {
+		warn(WarningInvalidDate, "%d dates could not be parsed (e.g. %q)", len(invalidDates), invalidDates[0])
+	}
+	if len(relativeLinks) > 0 {
+		warn(WarningRelativeURL, "%d of %d items have relative links (e.g. %q)", len(relativeLinks), len(check.items), relativeLinks[0])
+	}
+	if check.idLabel != "" && missingIDs > 0 {
+		warn(WarningMissingGUID, "%d of %d items have no %s", missingIDs, len(check.items), check.idLabel)
+	}
+
+	return warnings
+}
+
+// buildFeedCheck parses content as each supported format in turn
+func buildFeedCheck(content string) (*feedCheck, bool) {
+	content = trimFeedPrefix(content)
+	for _, build := range []func(string) (*feedCheck, bool){
+		rssFeedCheck,
+		atomFeedCheck,
+		rdfFeedCheck,
+		jsonFeedCheck,
+	} {
+		if check, ok := build(content); ok {
+			return check, true
+		}
+	}
+	return nil, false
+}
+
+// rssFeedCheck reads an RSS 2.0 feed for validation
+func rssFeedCheck(content string) (*feedCheck, bool) {
+	var doc struct {
+		XMLName xml.Name `xml:"rss"`
+		Channel struct {
+			Title         string        `xml:"title"`
+			Links         []ChannelLink `xml:"link"`
+			LastBuildDate string        `xml:"lastBuildDate"`
+			PubDate       string        `xml:"pubDate"`
+			Items         []struct {
+				Link    string `xml:"link"`
+				GUID    string `xml:"guid"`
+				PubDate string `xml:"pubDate"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := decodeFeedXML(content, &doc); err != nil {
+		return nil, false
+	}
+
+	check := &feedCheck{
+		format:  "RSS",
+		title:   strings.TrimSpace(doc.Channel.Title),
+		link:    channelSiteURL(doc.Channel.Links),
+		dates:   []string{doc.Channel.LastBuildDate, doc.Channel.PubDate},
+		idLabel: "guid",
+	}
+	for _, item := range doc.Channel.Items {
+		check.items = append(check.items, itemCheck{
+			link:  strings.TrimSpace(item.Link),
+			id:    strings.TrimSpace(item.GUID),
+			dates: []string{item.PubDate},
+		})
+	}
+	return check, true
+}
+
+// atomFeedCheck reads an Atom feed for validation
+func atomFeedCheck(content string) (*feedCheck, bool) {
+	var doc struct {
+		XMLName xml.Name   `xml:"feed"`
+		Title   string     `xml:"title"`
+		Updated string     `xml:"updated"`
+		Links   []AtomLink `xml:"link"`
+		Entries []struct {
+			ID        string     `xml:"id"`
+			Links     []AtomLink `xml:"link"`
+			Updated   string     `xml:"updated"`
+			Published string     `xml:"published"`
+		} `xml:"entry"`
+	}
+	if err := decodeFeedXML(content, &doc); err != nil {
+		return nil, false
+	}
+
+	check := &feedCheck{
+		format:  "Atom",
+		title:   strings.TrimSpace(doc.Title),
+		link:    alternateLink(doc.Links),
+		dates:   []string{doc.Updated},
+		idLabel: "id",
+	}
+	for _, entry := range doc.Entries {
+		check.items = append(check.items, itemCheck{
+			link:  alternateLink(entry.Links),
+			id:    strings.TrimSpace(entry.ID),
+			dates: []string{entry.Updated, entry.Published},
+		})
+	}
+	return check, true
+}
+
+// rdfFeedCheck reads an RSS 1.0 (RDF) feed for validation. RDF items are
+// identified by their rdf:about attribute.
+func rdfFeedCheck(content string) (*feedCheck, bool) {
+	var doc struct {
+		XMLName xml.Name `xml:"RDF"`
+		Channel struct {
+			Title  string        `xml:"title"`
+			Links  []ChannelLink `xml:"link"`
+			DCDate string        `xml:"http://purl.org/dc/elements/1.1/ date"`
+		} `xml:"channel"`
+		Items []struct {
+			About  string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+			Link   string `xml:"link"`
+			DCDate string `xml:"http://purl.org/dc/elements/1.1/ date"`
+		} `xml:"item"`
+	}
+	if err := decodeFeedXML(content, &doc); err != nil {
+		return nil, false
+	}
+
+	check := &feedCheck{
+		format:  "RDF",
+		title:   strings.TrimSpace(doc.Channel.Title),
+		link:    channelSiteURL(doc.Channel.Links),
+		dates:   []string{doc.Channel.DCDate},
+		idLabel: "rdf:about",
+	}
+	for _, item := range doc.Items {
+		check.items = append(check.items, itemCheck{
+			link:  strings.TrimSpace(item.Link),
+			id:    strings.TrimSpace(item.About),
+			dates: []string{item.DCDate},
+		})
+	}
+	return check, true
+}
+
+// jsonFeedCheck reads a JSON Feed for validation
+func jsonFeedCheck(content string) (*feedCheck, bool) {
+	var doc struct {
+		Version     string `json:"version"`
+		Title       string `json:"title"`
+		HomePageURL string `json:"home_page_url"`
+		Items       []struct {
+			ID            json.RawMessage `json:"id"` // should be a string, but numbers are common
+			URL           string          `json:"url"`
+			DatePublished string          `json:"date_published"`
+			DateModified  string          `json:"date_modified"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, false
+	}
+	if !strings.HasPrefix(doc.Version, "https://jsonfeed.org/version/") {
+		return nil, false
+	}
+
+	check := &feedCheck{
+		format:  "JSON",
+		title:   strings.TrimSpace(doc.Title),
+		link:    strings.TrimSpace(doc.HomePageURL),
+		idLabel: "id",
+	}
+	for _, item := range doc.Items {
+		id := strings.Trim(strings.TrimSpace(string(item.ID)), `"`)
+		if id == "null" {
+			id = ""
+		}
+		check.items = append(check.items, itemCheck{
+			link:  strings.TrimSpace(item.URL),
+			id:    id,
+			dates: []string{item.DatePublished, item.DateModified},
+		})
+	}
+	return check, true
+}
+
+// alternateLink returns the Atom link with rel="alternate", or with no rel,
+// which defaults to alternate
+func alternateLink(links []AtomLink) string {
+	for _, link := range links {
+		rel := strings.TrimSpace(link.Rel)
+		if (rel == "" || strings.EqualFold(rel, "alternate")) && strings.TrimSpace(link.Href) != "" {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	return ""
+}
+
+// isAbsoluteURL returns true if the value parses as a URL with a scheme
+func isAbsoluteURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && parsed.IsAbs()
+}
@@ -1,7 +1,12 @@
 package linkding
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	neturl "net/url"
+	"path"
 	"strings"
 	"time"
 
@@ -9,16 +14,40 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// ErrUnauthorized indicates Linkding rejected the API token
+var ErrUnauthorized = errors.New("linkding rejected the API token (HTTP 401); check linkding.token and linkding.url")
+
+// ErrNotFound indicates the Linkding API wasn't found at the configured URL
+var ErrNotFound = errors.New("linkding API not found (HTTP 404); check that linkding.url points at your Linkding instance")
+
+// ErrUnreachable indicates no connection could be made to the configured Linkding URL
+var ErrUnreachable = errors.New("could not reach linkding; check linkding.url and your network connection")
+
+// ErrNotLinkdingAPI indicates the configured URL answered, but not with the Linkding API
+var ErrNotLinkdingAPI = errors.New("linkding.url answered, but not with the Linkding API; check it points at your Linkding instance and not a login page or another site")
+
 // Bookmark represents a bookmark from Linkding
 type Bookmark struct {
-	URL   string   `json:"url"`
-	Title string   `json:"title"`
-	Tags  []string `json:"tags"`
+	ID           int       `json:"id"`
+	URL          string    `json:"url"`
+	Title        string    `json:"title"`
+	Tags         []string  `json:"tags"`
+	DateAdded    time.Time `json:"date_added"`
+	DateModified time.Time `json:"date_modified"`
+}
+
+// LastChanged returns the most recent of the bookmark's added and modified dates
+func (b *Bookmark) LastChanged() time.Time {
+	if b.DateModified.After(b.DateAdded) {
+		return b.DateModified
+	}
+	return b.DateAdded
 }
 
 // Client wraps the go-linkding client with additional functionality
 type Client struct {
 	client  *linkding.Client
+	url     string
 	timeout time.Duration
 }
 
@@ -31,6 +60,11 @@ func NewClient(token, url string, timeout time.Duration) (*Client, error) {
 		return nil, fmt.Errorf("linkding URL cannot be empty")
 	}
 
+	url, err := normalizeURL(url)
+	if err != nil {
+		return nil, err
+	}
+
 	client := linkding.NewClient(url, token)
 
 	logrus.WithFields(logrus.Fields{
@@ -40,10 +74,85 @@ func NewClient(token, url string, timeout time.Duration) (*Client, error) {
 
 	return &Client{
 		client:  client,
+		url:     url,
 		timeout: timeout,
 	}, nil
 }
 
+// normalizeURL tidies a configured Linkding URL into the base URL go-linkding
+// expects: https:// is assumed when no scheme is given and trailing slashes
+// are removed, since API paths are appended verbatim. A URL that already
+// points into /api is rejected.
+func normalizeURL(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if !strings.Contains(rawURL, "://") {
+		logrus.WithField("url", rawURL).Warn("Linkding URL has no scheme, assuming https://")
+		rawURL = "https://" + rawURL
+	}
+
+	parsed, err := neturl.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid linkding URL %q: %w", rawURL, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", fmt.Errorf("invalid linkding URL %q: scheme must be http or https", rawURL)
+	}
+	if parsed.Host == "" {
+		return "", fmt.Errorf("invalid linkding URL %q: no host name", rawURL)
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return "", fmt.Errorf("invalid linkding URL %q: must not have a query string or fragment", rawURL)
+	}
+
+	parsed.Path = strings.TrimRight(parsed.Path, "/")
+	parsed.RawPath = ""
+	if strings.Contains(parsed.Path+"/", "/api/") {
+		return "", fmt.Errorf("invalid linkding URL %q: must be the instance's base URL without /api, which is added automatically", rawURL)
+	}
+
+	return parsed.String(), nil
+}
+
+// ConfigureDefaultTransport applies transport settings (TLS, proxy) to requests
+// made to Linkding. The go-linkding library doesn't expose its HTTP client and
+// relies on http.DefaultTransport, so this adjusts the default transport process-wide.
+func ConfigureDefaultTransport(configure func(*http.Transport) error) error {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("default HTTP transport is not an *http.Transport")
+	}
+
+	if err := configure(transport); err != nil {
+		return err
+	}
+
+	logrus.Debug("Applied custom transport settings to Linkding API client")
+	return nil
+}
+
+// Ping checks that Linkding is reachable at the configured URL and accepts the
+// token by listing a single bookmark. The error names the likely cause: a bad
+// token, a bad URL or a network problem.
+func (c *Client) Ping() error {
+	_, err := c.client.ListBookmarks(linkding.ListBookmarksParams{Limit: 1})
+	if err == nil {
+		logrus.WithField("url", c.url).Debug("Linkding API is reachable and accepted the token")
+		return nil
+	}
+
+	var urlErr *neturl.Error
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &urlErr):
+		return fmt.Errorf("%w: %v", ErrUnreachable, urlErr.Err)
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return fmt.Errorf("%w (%v)", ErrNotLinkdingAPI, err)
+	default:
+		return classifyError(err)
+	}
+}
+
 // FetchBookmarks fetches bookmarks from Linkding, optionally filtered by tags
 func (c *Client) FetchBookmarks(tags []string) ([]*Bookmark, error) {
 	logrus.WithField("tags", tags).Info("Fetching bookmarks from Linkding API")
@@ -56,7 +165,7 @@ func (c *Client) FetchBookmarks(tags []string) ([]*Bookmark, error) {
 		Offset: 0,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch bookmarks from Linkding: %w", err)
+		return nil, fmt.Errorf("failed to fetch bookmarks from Linkding: %w", classifyError(err))
 	}
 
 	var filteredBookmarks []*Bookmark
@@ -68,9 +177,12 @@ func (c *Client) FetchBookmarks(tags []string) ([]*Bookmark, error) {
 		copy(bookmarkTags, bookmark.TagNames)
 
 		internalBookmark := &Bookmark{
-			URL:   bookmark.URL,
-			Title: bookmark.Title,
-			Tags:  bookmarkTags,
+			ID:           bookmark.ID,
+			URL:          bookmark.URL,
+			Title:        bookmark.Title,
+			Tags:         bookmarkTags,
+			DateAdded:    bookmark.DateAdded,
+			DateModified: bookmark.DateModified,
 		}
 
 		// Apply tag filtering if tags are specified
@@ -93,7 +205,58 @@ func (c *Client) FetchBookmarks(tags []string) ([]*Bookmark, error) {
 	return filteredBookmarks, nil
 }
 
-// matchesTags checks if a bookmark has ALL the specified tags (AND operation)
+// DeleteBookmark permanently deletes the bookmark with the given ID from Linkding
+func (c *Client) DeleteBookmark(id int) error {
+	logrus.WithField("id", id).Debug("Deleting bookmark from Linkding")
+
+	if err := c.client.DeleteBookmark(id); err != nil {
+		return fmt.Errorf("failed to delete bookmark %d from Linkding: %w", id, classifyError(err))
+	}
+
+	return nil
+}
+
+// classifyError maps go-linkding's status errors onto this package's typed
+// errors, which carry a hint about what to fix
+func classifyError(err error) error {
+	switch {
+	case errors.Is(err, linkding.ErrUnauthorized):
+		return ErrUnauthorized
+	case errors.Is(err, linkding.ErrNotFound):
+		return ErrNotFound
+	default:
+		return err
+	}
+}
+
+// FilterSince returns the bookmarks added or modified at or after the cutoff
+func FilterSince(bookmarks []*Bookmark, cutoff time.Time) []*Bookmark {
+	var recent []*Bookmark
+	for _, bookmark := range bookmarks {
+		if bookmark.LastChanged().Before(cutoff) {
+			logrus.WithFields(logrus.Fields{
+				"url":           bookmark.URL,
+				"date_added":    bookmark.DateAdded,
+				"date_modified": bookmark.DateModified,
+				"cutoff":        cutoff,
+			}).Debug("Bookmark is older than the --since cutoff")
+			continue
+		}
+		recent = append(recent, bookmark)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"before_filter": len(bookmarks),
+		"after_filter":  len(recent),
+		"cutoff":        cutoff,
+	}).Info("Filtered bookmarks by date")
+
+	return recent
+}
+
+// matchesTags checks if a bookmark has ALL the specified tags (AND operation).
+// A required tag containing * is a glob pattern matched with path.Match, so
+// news/* matches news/tech but not news/tech/go or devnews.
 func (c *Client) matchesTags(bookmark *Bookmark, requiredTags []string) bool {
 	if len(requiredTags) == 0 {
 		return true // No filter tags means match all
@@ -107,7 +270,7 @@ func (c *Client) matchesTags(bookmark *Bookmark, requiredTags []string) bool {
 
 	// Check if bookmark has ALL required tags (AND operation)
 	for _, requiredTag := range requiredTags {
-		if !bookmarkTags[strings.ToLower(requiredTag)] {
+		if !hasTag(bookmarkTags, strings.ToLower(requiredTag)) {
 			logrus.WithFields(logrus.Fields{
 				"url":           bookmark.URL,
 				"bookmark_tags": bookmark.Tags,
@@ -126,3 +289,19 @@ func (c *Client) matchesTags(bookmark *Bookmark, requiredTags []string) bool {
 
 	return true
 }
+
+// hasTag reports whether any of the lowercased bookmark tags equals the
+// required tag, or matches it when the required tag is a glob pattern
+func hasTag(bookmarkTags map[string]bool, requiredTag string) bool {
+	if !strings.Contains(requiredTag, "*") {
+		return bookmarkTags[requiredTag]
+	}
+
+	for tag := range bookmarkTags {
+		// An invalid pattern matches nothing
+		if matched, _ := path.Match(requiredTag, tag); matched {
+			return true
+		}
+	}
+	return false
+}
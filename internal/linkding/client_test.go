@@ -0,0 +1,80 @@
+package linkding
+
+import "testing"
+
+func TestMatchesTags(t *testing.T) {
+	tests := []struct {
+		name         string
+		bookmarkTags []string
+		requiredTags []string
+		want         bool
+	}{
+		{"no filter", []string{"news"}, nil, true},
+		{"exact match", []string{"news", "go"}, []string{"news"}, true},
+		{"exact match ignores case", []string{"News"}, []string{"NEWS"}, true},
+		{"exact tag missing", []string{"news"}, []string{"blog"}, false},
+		{"exact match is not a prefix match", []string{"news/tech"}, []string{"news"}, false},
+		{"all tags required", []string{"news", "go"}, []string{"news", "go"}, true},
+		{"one of several tags missing", []string{"news"}, []string{"news", "go"}, false},
+		{"glob matches nested tag", []string{"news/tech"}, []string{"news/*"}, true},
+		{"glob ignores case", []string{"News/Tech"}, []string{"news/*"}, true},
+		{"glob does not match other prefix", []string{"devnews"}, []string{"news/*"}, false},
+		{"glob does not match bare parent", []string{"news"}, []string{"news/*"}, false},
+		{"glob combined with exact tag", []string{"news/tech", "go"}, []string{"news/*", "go"}, true},
+		{"invalid glob matches nothing", []string{"news["}, []string{"news[*"}, false},
+	}
+
+	c := &Client{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bookmark := &Bookmark{URL: "https://example.com/", Tags: tt.bookmarkTags}
+			if got := c.matchesTags(bookmark, tt.requiredTags); got != tt.want {
+				t.Errorf("matchesTags(%v, %v) = %v, want %v", tt.bookmarkTags, tt.requiredTags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		rawURL  string
+		want    string
+		wantErr bool
+	}{
+		{name: "already correct", rawURL: "https://links.example.com", want: "https://links.example.com"},
+		{name: "http kept", rawURL: "http://localhost:9090", want: "http://localhost:9090"},
+		{name: "subpath kept", rawURL: "https://example.com/linkding", want: "https://example.com/linkding"},
+		{name: "no scheme", rawURL: "links.example.com", want: "https://links.example.com"},
+		{name: "no scheme with port", rawURL: "localhost:9090", want: "https://localhost:9090"},
+		{name: "trailing slash", rawURL: "https://links.example.com/", want: "https://links.example.com"},
+		{name: "trailing slashes on subpath", rawURL: "https://example.com/linkding//", want: "https://example.com/linkding"},
+		{name: "surrounding whitespace", rawURL: "  https://links.example.com/ ", want: "https://links.example.com"},
+		{name: "query string", rawURL: "https://links.example.com/?q=go", wantErr: true},
+		{name: "fragment", rawURL: "https://links.example.com/#top", wantErr: true},
+		{name: "api path", rawURL: "https://links.example.com/api", wantErr: true},
+		{name: "api path with trailing slash", rawURL: "https://links.example.com/api/", wantErr: true},
+		{name: "api bookmarks path", rawURL: "https://links.example.com/api/bookmarks/", wantErr: true},
+		{name: "api under subpath", rawURL: "https://example.com/linkding/api", wantErr: true},
+		{name: "unsupported scheme", rawURL: "ftp://links.example.com", wantErr: true},
+		{name: "no host", rawURL: "https:///bookmarks", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeURL(tt.rawURL)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("normalizeURL(%q) = %q, want an error", tt.rawURL, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeURL(%q) error = %v", tt.rawURL, err)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeURL(%q) = %q, want %q", tt.rawURL, got, tt.want)
+			}
+		})
+	}
+}
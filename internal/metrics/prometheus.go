@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/lmorchard/linkding-to-opml/internal/atomicfile"
+	"github.com/lmorchard/linkding-to-opml/internal/feeds"
+
+	"github.com/sirupsen/logrus"
+)
+
+// metricPrefix namespaces every metric written by this tool
+const metricPrefix = "linkding_to_opml_"
+
+// metric is a single gauge in the Prometheus text exposition format
+type metric struct {
+	name  string
+	help  string
+	value float64
+}
+
+// WriteText writes the run's processing statistics in the Prometheus text
+// exposition format. stats may be nil when the run ended before processing;
+// failed marks whether the run returned an error.
+func WriteText(w io.Writer, stats *feeds.ProcessingStats, failed bool, finished time.Time) error {
+	if stats == nil {
+		stats = &feeds.ProcessingStats{}
+	}
+
+	success := 1.0
+	if failed {
+		success = 0
+	}
+
+	gauges := []metric{
+		{"bookmarks_total", "Bookmarks considered for feed discovery", float64(stats.TotalBookmarks)},
+		{"feeds_successful", "Bookmarks with a discovered feed", float64(stats.SuccessfulFeeds)},
+		{"discoveries_failed", "Bookmarks whose feed discovery failed", float64(stats.FailedDiscoveries)},
+		{"cache_hits", "Bookmarks answered from the cache", float64(stats.CacheHits)},
+		{"new_discoveries", "Bookmarks discovered with fresh requests", float64(stats.NewDiscoveries)},
+		{"processing_duration_seconds", "Time spent on feed discovery", stats.ProcessingTime.Seconds()},
+		{"last_run_success", "Whether the last run completed without error", success},
+		{"last_run_timestamp_seconds", "Unix time the last run finished", float64(finished.Unix())},
+	}
+
+	for _, g := range gauges {
+		name := metricPrefix + g.name
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, g.help, name, name, strconv.FormatFloat(g.value, 'f', -1, 64)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteTextFile writes the metrics to filePath for a node_exporter textfile
+// collector. The file is replaced atomically so a scrape never sees a partial write.
+func WriteTextFile(filePath string, stats *feeds.ProcessingStats, failed bool, finished time.Time) error {
+	var buf bytes.Buffer
+	if err := WriteText(&buf, stats, failed, finished); err != nil {
+		return fmt.Errorf("failed to format metrics: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(filePath, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+
+	logrus.WithField("file_path", filePath).Debug("Wrote metrics file")
+	return nil
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// When a run should trigger a notification
+const (
+	OnAlways  = "always"
+	OnFailure = "failure"
+)
+
+// Summary is the JSON body posted to the webhook when an export run completes
+type Summary struct {
+	Status            string  `json:"status"` // "success" or "failure"
+	Error             string  `json:"error,omitempty"`
+	Output            string  `json:"output"`
+	TotalBookmarks    int     `json:"total_bookmarks"`
+	SuccessfulFeeds   int     `json:"successful_feeds"`
+	FailedDiscoveries int     `json:"failed_discoveries"`
+	CacheHits         int     `json:"cache_hits"`
+	NewDiscoveries    int     `json:"new_discoveries"`
+	Interrupted       bool    `json:"interrupted"`
+	DurationSeconds   float64 `json:"duration_seconds"`
+	StartTime         string  `json:"start_time"`
+	EndTime           string  `json:"end_time"`
+}
+
+// Failed returns true if the run ended with an error
+func (s *Summary) Failed() bool {
+	return s.Status == "failure"
+}
+
+// ShouldSend returns true if a summary should be posted under the given mode
+func ShouldSend(mode string, summary *Summary) bool {
+	return mode != OnFailure || summary.Failed()
+}
+
+// SendWebhook posts the summary as JSON to webhookURL, treating any non-2xx
+// response as an error
+func SendWebhook(ctx context.Context, webhookURL string, summary *Summary, timeout time.Duration) error {
+	body, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "linkding-to-opml")
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notification webhook returned HTTP %d", resp.StatusCode)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"status":      summary.Status,
+		"status_code": resp.StatusCode,
+	}).Info("Sent completion notification")
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package opml
+
+import (
+	"sort"
+
+	"github.com/lmorchard/linkding-to-opml/internal/feeds"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LimitFeedsPerDomain keeps at most max feed outlines per registrable domain
+// (eTLD+1, so blog.example.co.uk and www.example.co.uk count together),
+// preferring the feeds that rank as a site's main feed and then the earliest.
+// Outlines are grouped by their website URL rather than the feed URL, so feeds
+// hosted on a shared service like FeedBurner aren't lumped together. It returns
+// the number of outlines dropped per domain.
+func LimitFeedsPerDomain(opml *OPML, max int) map[string]int {
+	dropped := make(map[string]int)
+	if max <= 0 {
+		return dropped
+	}
+
+	byDomain := make(map[string][]int)
+	for i, outline := range opml.Body.Outlines {
+		if outline.IsFolder() || outline.IsUnreachable() || outline.XMLURL == "" {
+			continue
+		}
+		domain := outlineDomain(outline)
+		byDomain[domain] = append(byDomain[domain], i)
+	}
+
+	drop := make(map[int]bool)
+	for domain, indexes := range byDomain {
+		if len(indexes) <= max {
+			continue
+		}
+
+		sort.SliceStable(indexes, func(i, j int) bool {
+			a, b := opml.Body.Outlines[indexes[i]], opml.Body.Outlines[indexes[j]]
+			return feeds.ScoreFeed(a.XMLURL, a.Title) > feeds.ScoreFeed(b.XMLURL, b.Title)
+		})
+		for _, index := range indexes[max:] {
+			drop[index] = true
+		}
+		dropped[domain] = len(indexes) - max
+
+		logrus.WithFields(logrus.Fields{
+			"domain":  domain,
+			"feeds":   len(indexes),
+			"kept":    max,
+			"dropped": len(indexes) - max,
+		}).Info("Dropped feeds over the per-domain limit")
+	}
+
+	if len(drop) == 0 {
+		return dropped
+	}
+
+	kept := make([]Outline, 0, len(opml.Body.Outlines)-len(drop))
+	for i, outline := range opml.Body.Outlines {
+		if !drop[i] {
+			kept = append(kept, outline)
+		}
+	}
+	opml.Body.Outlines = kept
+
+	return dropped
+}
+
+// outlineDomain returns the registrable domain of an outline's website URL,
+// falling back to its feed URL, or the bare host name when it has no public
+// suffix (an IP address or localhost)
+func outlineDomain(outline Outline) string {
+	rawURL := outline.HTMLURL
+	if rawURL == "" {
+		rawURL = outline.XMLURL
+	}
+	return feeds.RegistrableDomain(rawURL)
+}
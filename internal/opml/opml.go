@@ -3,15 +3,26 @@ package opml
 import (
 	"encoding/xml"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
-	"linkding-to-opml/internal/feeds"
+	"github.com/lmorchard/linkding-to-opml/internal/atomicfile"
+	"github.com/lmorchard/linkding-to-opml/internal/feeds"
 
 	"github.com/sirupsen/logrus"
 )
 
+// StdioPath is the special file path meaning stdin (for reading) or stdout (for writing)
+const StdioPath = "-"
+
+// BackupTimestampLayout is the timestamp format used in OPML backup file names
+const BackupTimestampLayout = "20060102-150405"
+
 // OPML represents the root OPML document structure
 type OPML struct {
 	XMLName xml.Name `xml:"opml"`
@@ -37,14 +48,34 @@ type Body struct {
 	Outlines []Outline `xml:"outline"`
 }
 
-// Outline represents a feed entry in the OPML
+// Outline represents a feed entry (or a folder of entries) in the OPML
 type Outline struct {
-	XMLName xml.Name `xml:"outline"`
-	Title   string   `xml:"title,attr"`
-	Text    string   `xml:"text,attr"`
-	XMLURL  string   `xml:"xmlUrl,attr"`
-	HTMLURL string   `xml:"htmlUrl,attr"`
-	Type    string   `xml:"type,attr,omitempty"`
+	XMLName  xml.Name   `xml:"outline"`
+	Title    string     `xml:"title,attr,omitempty"`
+	Text     string     `xml:"text,attr"`
+	XMLURL   string     `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string     `xml:"htmlUrl,attr,omitempty"`
+	Type     string     `xml:"type,attr,omitempty"`
+	Language string     `xml:"language,attr,omitempty"`
+	IconURL  string     `xml:"iconUrl,attr,omitempty"`
+	HubURL   string     `xml:"hubUrl,attr,omitempty"`
+	Category string     `xml:"category,attr,omitempty"`
+	Error    string     `xml:"error,attr,omitempty"` // Discovery error for unreachable outlines
+	Attrs    []xml.Attr `xml:",any,attr"`            // Unrecognized attributes, preserved when reading existing files
+	Outlines []Outline  `xml:"outline"`              // Child outlines when this outline is a folder
+}
+
+// UnreachableType is the outline type used to record bookmarks whose feed discovery failed
+const UnreachableType = "unreachable"
+
+// IsUnreachable returns true if the outline records a failed discovery rather than a feed
+func (o *Outline) IsUnreachable() bool {
+	return o.Type == UnreachableType
+}
+
+// IsFolder returns true if the outline groups child outlines rather than describing a feed
+func (o *Outline) IsFolder() bool {
+	return len(o.Outlines) > 0 && o.XMLURL == ""
 }
 
 // GenerateOPML creates an OPML document from feed discovery results
@@ -54,17 +85,9 @@ func GenerateOPML(results []*feeds.FeedDiscoveryResult, title string) *OPML {
 		"title":      title,
 	}).Debug("Generating OPML document")
 
-	now := time.Now().Format(time.RFC1123)
-
 	opml := &OPML{
-		Version: "2.0",
-		Head: Head{
-			Title:        title,
-			DateCreated:  now,
-			DateModified: now,
-			OwnerName:    "linkding-to-opml",
-			Docs:         "http://www.opml.org/spec2",
-		},
+		Version: DefaultVersion,
+		Head:    newHead(title),
 		Body: Body{
 			Outlines: make([]Outline, 0, len(results)),
 		},
@@ -73,15 +96,7 @@ func GenerateOPML(results []*feeds.FeedDiscoveryResult, title string) *OPML {
 	// Convert feed discovery results to OPML outlines
 	for _, result := range results {
 		if result.IsSuccessful() {
-			outline := Outline{
-				Title:   result.FeedTitle,
-				Text:    result.FeedTitle,
-				XMLURL:  result.FeedURL,
-				HTMLURL: result.URL,
-				Type:    "rss", // Default to RSS type for feed readers
-			}
-
-			opml.Body.Outlines = append(opml.Body.Outlines, outline)
+			opml.Body.Outlines = append(opml.Body.Outlines, feedOutline(result))
 
 			logrus.WithFields(logrus.Fields{
 				"feed_title": result.FeedTitle,
@@ -96,35 +111,434 @@ func GenerateOPML(results []*feeds.FeedDiscoveryResult, title string) *OPML {
 	return opml
 }
 
-// WriteOPML writes an OPML document to a file
+// newHead creates the head of a newly generated document
+func newHead(title string) Head {
+	now := time.Now().Format(time.RFC1123)
+	return Head{
+		Title:        title,
+		DateCreated:  now,
+		DateModified: now,
+		OwnerName:    "linkding-to-opml",
+		Docs:         versionProfiles[DefaultVersion].docs,
+	}
+}
+
+// feedOutline creates the outline for a successful discovery result
+func feedOutline(result *feeds.FeedDiscoveryResult) Outline {
+	// Entries cached before feed types were recorded default to RSS
+	feedType := result.FeedType
+	if feedType == "" {
+		feedType = feeds.FeedTypeRSS
+	}
+
+	return Outline{
+		Title:    result.FeedTitle,
+		Text:     result.FeedTitle,
+		XMLURL:   result.FeedURL,
+		HTMLURL:  result.URL,
+		Type:     feedType,
+		Language: result.Language,
+		HubURL:   result.HubURL,
+	}
+}
+
+// AddFeedIcons sets the non-standard iconUrl attribute, which some feed readers
+// display, on each feed outline whose discovery result recorded an icon
+func AddFeedIcons(opml *OPML, results []*feeds.FeedDiscoveryResult) {
+	icons := make(map[string]string, len(results))
+	for _, result := range results {
+		if result.IsSuccessful() && result.IconURL != "" {
+			icons[result.FeedURL] = result.IconURL
+		}
+	}
+
+	count := 0
+	for i := range opml.Body.Outlines {
+		outline := &opml.Body.Outlines[i]
+		if iconURL, ok := icons[outline.XMLURL]; ok {
+			outline.IconURL = iconURL
+			count++
+		}
+	}
+
+	logrus.WithField("icon_count", count).Debug("Added feed icons to OPML")
+}
+
+// AddFeedCategories sets the category attribute of each feed outline to the
+// comma-separated tags of the bookmarks it was discovered from. Feeds found
+// through several bookmarks get the tags of all of them.
+func AddFeedCategories(opml *OPML, results []*feeds.FeedDiscoveryResult) {
+	tags := make(map[string][]string, len(results))
+	seen := make(map[string]bool)
+	for _, result := range results {
+		if !result.IsSuccessful() {
+			continue
+		}
+		for _, tag := range result.Tags {
+			key := result.FeedURL + "\x00" + strings.ToLower(tag)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			tags[result.FeedURL] = append(tags[result.FeedURL], tag)
+		}
+	}
+
+	count := 0
+	for i := range opml.Body.Outlines {
+		outline := &opml.Body.Outlines[i]
+		if feedTags, ok := tags[outline.XMLURL]; ok {
+			outline.Category = strings.Join(feedTags, ",")
+			count++
+		}
+	}
+
+	logrus.WithField("categorized_count", count).Debug("Added feed categories to OPML")
+}
+
+// AddUnreachableOutlines appends an outline of type "unreachable" for each failed
+// discovery result, carrying the bookmark URL and the error text for auditing
+func AddUnreachableOutlines(opml *OPML, failed []*feeds.FeedDiscoveryResult) {
+	for _, result := range failed {
+		errorText := "unknown error"
+		if result.Error != nil {
+			errorText = result.Error.Error()
+		}
+
+		opml.Body.Outlines = append(opml.Body.Outlines, Outline{
+			Text:    result.URL,
+			HTMLURL: result.URL,
+			Type:    UnreachableType,
+			Error:   errorText,
+		})
+	}
+
+	logrus.WithField("unreachable_count", len(failed)).Debug("Added unreachable outlines to OPML")
+}
+
+// ReadOPML reads and parses an existing OPML file, or stdin when filePath is "-"
+func ReadOPML(filePath string) (*OPML, error) {
+	logrus.WithField("file_path", filePath).Debug("Reading OPML file")
+
+	var data []byte
+	var err error
+	if filePath == StdioPath {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(filePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OPML file: %w", err)
+	}
+
+	var doc OPML
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OPML file %s: %w", filePath, err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"file_path":     filePath,
+		"outline_count": len(doc.Body.Outlines),
+	}).Debug("Successfully read OPML file")
+
+	return &doc, nil
+}
+
+// AppendOPML appends all outlines from generated to the end of existing,
+// keeping the existing outlines and head metadata untouched (no deduplication)
+func AppendOPML(existing, generated *OPML) *OPML {
+	existing.Body.Outlines = append(existing.Body.Outlines, generated.Body.Outlines...)
+	existing.Head.DateModified = generated.Head.DateModified
+
+	if existing.Version == "" {
+		existing.Version = generated.Version
+	}
+	if existing.Head.Title == "" {
+		existing.Head.Title = generated.Head.Title
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"appended_count": len(generated.Body.Outlines),
+		"outline_count":  len(existing.Body.Outlines),
+	}).Info("Appended new outlines to existing OPML document")
+
+	return existing
+}
+
+// MergeNewOutlines appends to existing only the generated outlines whose feed
+// isn't already in it, anywhere in its folder tree. Existing outlines, their
+// order and the head metadata are left untouched. Unreachable outlines are
+// matched by bookmark URL. It returns the document and the number of outlines added.
+func MergeNewOutlines(existing, generated *OPML) (*OPML, int) {
+	seen := make(map[string]bool)
+	collectOutlineKeys(existing.Body.Outlines, seen)
+
+	added := 0
+	for _, outline := range generated.Body.Outlines {
+		key := outlineKey(outline)
+		if key != "" && seen[key] {
+			logrus.WithField("xml_url", outline.XMLURL).Debug("Feed already in existing OPML, not appending")
+			continue
+		}
+		if key != "" {
+			seen[key] = true
+		}
+		existing.Body.Outlines = append(existing.Body.Outlines, outline)
+		added++
+	}
+
+	if added > 0 {
+		existing.Head.DateModified = generated.Head.DateModified
+	}
+	if existing.Version == "" {
+		existing.Version = generated.Version
+	}
+	if existing.Head.Title == "" {
+		existing.Head.Title = generated.Head.Title
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"added_count":   added,
+		"skipped_count": len(generated.Body.Outlines) - added,
+		"outline_count": len(existing.Body.Outlines),
+	}).Info("Merged new outlines into existing OPML document")
+
+	return existing, added
+}
+
+// collectOutlineKeys records the key of every outline in the tree
+func collectOutlineKeys(outlines []Outline, seen map[string]bool) {
+	for _, outline := range outlines {
+		if outline.IsFolder() {
+			collectOutlineKeys(outline.Outlines, seen)
+			continue
+		}
+		if key := outlineKey(outline); key != "" {
+			seen[key] = true
+		}
+	}
+}
+
+// outlineKey identifies a feed outline by its xmlUrl, and an unreachable
+// outline by its bookmark URL
+func outlineKey(outline Outline) string {
+	if outline.IsUnreachable() {
+		if outline.HTMLURL == "" {
+			return ""
+		}
+		return UnreachableType + ":" + outline.HTMLURL
+	}
+	return outline.XMLURL
+}
+
+// MergeOPML combines the outlines of several OPML documents into a new document.
+// Feeds are deduplicated by xmlUrl (the first occurrence wins, keeping its title)
+// and folders with the same name are merged together. It returns the merged
+// document and the number of duplicate feeds that were dropped.
+func MergeOPML(docs []*OPML, title string) (*OPML, int) {
+	now := time.Now().Format(time.RFC1123)
+
+	merged := &OPML{
+		Version: "2.0",
+		Head: Head{
+			Title:        title,
+			DateCreated:  now,
+			DateModified: now,
+			OwnerName:    "linkding-to-opml",
+			Docs:         "http://www.opml.org/spec2",
+		},
+	}
+
+	seen := make(map[string]bool)
+	duplicates := 0
+	for _, doc := range docs {
+		merged.Body.Outlines = mergeOutlines(merged.Body.Outlines, doc.Body.Outlines, seen, &duplicates)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"input_count":   len(docs),
+		"outline_count": len(merged.Body.Outlines),
+		"duplicates":    duplicates,
+	}).Info("Merged OPML documents")
+
+	return merged, duplicates
+}
+
+// mergeOutlines merges src outlines into dst, skipping feeds whose xmlUrl was
+// already seen anywhere in the merged tree and merging same-named folders
+func mergeOutlines(dst, src []Outline, seen map[string]bool, duplicates *int) []Outline {
+	for _, outline := range src {
+		if outline.IsFolder() {
+			children := outline.Outlines
+			if i := findFolder(dst, outline); i >= 0 {
+				dst[i].Outlines = mergeOutlines(dst[i].Outlines, children, seen, duplicates)
+				continue
+			}
+			outline.Outlines = mergeOutlines(nil, children, seen, duplicates)
+			dst = append(dst, outline)
+			continue
+		}
+
+		if outline.XMLURL != "" {
+			if seen[outline.XMLURL] {
+				*duplicates++
+				logrus.WithField("xml_url", outline.XMLURL).Debug("Skipping duplicate feed while merging")
+				continue
+			}
+			seen[outline.XMLURL] = true
+		}
+
+		dst = append(dst, outline)
+	}
+
+	return dst
+}
+
+// findFolder returns the index of the folder in outlines with the same name as folder, or -1
+func findFolder(outlines []Outline, folder Outline) int {
+	name := folderName(folder)
+	for i := range outlines {
+		if outlines[i].IsFolder() && strings.EqualFold(folderName(outlines[i]), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// folderName returns the display name of a folder outline
+func folderName(outline Outline) string {
+	if outline.Text != "" {
+		return strings.TrimSpace(outline.Text)
+	}
+	return strings.TrimSpace(outline.Title)
+}
+
+// WriteOPML writes an OPML document to a file, or to stdout when filePath is "-".
+// The file is written to a temporary file next to it and renamed over the
+// target, so an existing file is only replaced by a complete document.
 func WriteOPML(opml *OPML, filePath string) error {
+	_, err := writeOPML(opml, filePath, false)
+	return err
+}
+
+// WriteOPMLWithBackup writes an OPML document to a file like WriteOPML, first
+// renaming an existing file at that path to <name>.<timestamp>.opml.bak.
+// It returns the backup path, or an empty string if there was nothing to back up.
+func WriteOPMLWithBackup(opml *OPML, filePath string) (string, error) {
+	return writeOPML(opml, filePath, true)
+}
+
+// backupPath builds the backup file name for an OPML file at the given time
+func backupPath(filePath string, now time.Time) string {
+	base := strings.TrimSuffix(filePath, filepath.Ext(filePath))
+	return fmt.Sprintf("%s.%s.opml.bak", base, now.Format(BackupTimestampLayout))
+}
+
+// writeOPML writes an OPML document to a file or stdout, optionally backing up an existing file
+func writeOPML(opml *OPML, filePath string, backup bool) (string, error) {
 	logrus.WithField("file_path", filePath).Info("Writing OPML file")
 
-	// Create directory if it doesn't exist
+	if filePath == StdioPath {
+		if err := EncodeOPML(opml, os.Stdout); err != nil {
+			return "", err
+		}
+		// Terminate the document so shell pipelines get a complete final line
+		if _, err := io.WriteString(os.Stdout, "\n"); err != nil {
+			return "", fmt.Errorf("failed to write newline: %w", err)
+		}
+
+		logrus.WithField("outline_count", len(opml.Body.Outlines)).Info("Successfully wrote OPML to stdout")
+		return "", nil
+	}
+
+	if err := createParentDir(filePath); err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file first so a failed write never leaves a
+	// truncated OPML file where a good one used to be
+	file, err := atomicfile.CreateTemp(filePath, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary OPML file: %w", err)
+	}
+	tempFile := file.Name()
+	if err := EncodeOPML(opml, file); err != nil {
+		file.Close()
+		os.Remove(tempFile)
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tempFile)
+		return "", fmt.Errorf("failed to write OPML file: %w", err)
+	}
+
+	backedUp, err := replaceFile(tempFile, filePath, backup)
+	if err != nil {
+		return backedUp, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"file_path":     filePath,
+		"outline_count": len(opml.Body.Outlines),
+	}).Info("Successfully wrote OPML file")
+
+	return backedUp, nil
+}
+
+// createParentDir creates the directory of filePath if it doesn't exist
+func createParentDir(filePath string) error {
 	dir := filepath.Dir(filePath)
 	if dir != "." && dir != "" {
 		if err := os.MkdirAll(dir, 0o755); err != nil {
 			return fmt.Errorf("failed to create directory %s: %w", dir, err)
 		}
 	}
+	return nil
+}
 
-	// Create the file
-	file, err := os.Create(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to create OPML file: %w", err)
+// replaceFile renames a fully written tempFile over filePath, first moving an
+// existing file aside when backup is set. tempFile is removed on failure.
+// It returns the backup path, or an empty string if nothing was backed up.
+func replaceFile(tempFile, filePath string, backup bool) (string, error) {
+	// Move the previous export aside before replacing it
+	var backedUp string
+	if backup {
+		if _, err := os.Stat(filePath); err == nil {
+			backedUp = backupPath(filePath, time.Now())
+			if err := os.Rename(filePath, backedUp); err != nil {
+				os.Remove(tempFile)
+				return "", fmt.Errorf("failed to back up existing OPML file: %w", err)
+			}
+			logrus.WithFields(logrus.Fields{
+				"file_path":   filePath,
+				"backup_path": backedUp,
+			}).Info("Backed up existing OPML file")
+		} else if !os.IsNotExist(err) {
+			os.Remove(tempFile)
+			return "", fmt.Errorf("failed to check existing OPML file: %w", err)
+		}
 	}
-	defer file.Close()
 
+	// Atomically replace the old OPML file
+	if err := os.Rename(tempFile, filePath); err != nil {
+		os.Remove(tempFile)
+		return backedUp, fmt.Errorf("failed to replace OPML file: %w", err)
+	}
+	return backedUp, nil
+}
+
+// EncodeOPML writes an OPML document, including the XML declaration, to w
+func EncodeOPML(opml *OPML, w io.Writer) error {
 	// Write XML declaration
-	if _, err := file.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`); err != nil {
+	if _, err := io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?>`); err != nil {
 		return fmt.Errorf("failed to write XML declaration: %w", err)
 	}
-	if _, err := file.WriteString("\n"); err != nil {
+	if _, err := io.WriteString(w, "\n"); err != nil {
 		return fmt.Errorf("failed to write newline: %w", err)
 	}
 
 	// Create XML encoder with indentation
-	encoder := xml.NewEncoder(file)
+	encoder := xml.NewEncoder(w)
 	encoder.Indent("", "  ")
 
 	// Encode OPML structure
@@ -137,11 +551,6 @@ func WriteOPML(opml *OPML, filePath string) error {
 		return fmt.Errorf("failed to flush XML encoder: %w", err)
 	}
 
-	logrus.WithFields(logrus.Fields{
-		"file_path":     filePath,
-		"outline_count": len(opml.Body.Outlines),
-	}).Info("Successfully wrote OPML file")
-
 	return nil
 }
 
@@ -151,7 +560,7 @@ func ValidateOPML(opml *OPML) error {
 		return fmt.Errorf("OPML document is nil")
 	}
 
-	if opml.Version != "2.0" {
+	if !IsSupportedVersion(opml.Version) {
 		return fmt.Errorf("unsupported OPML version: %s", opml.Version)
 	}
 
@@ -164,31 +573,213 @@ func ValidateOPML(opml *OPML) error {
 	}
 
 	// Validate outlines
-	for i, outline := range opml.Body.Outlines {
+	if err := validateOutlines(opml.Body.Outlines, ""); err != nil {
+		return err
+	}
+
+	logrus.WithField("outline_count", len(opml.Body.Outlines)).Debug("OPML validation passed")
+
+	return nil
+}
+
+// validateOutlines validates a list of outlines, recursing into folders
+func validateOutlines(outlines []Outline, prefix string) error {
+	for i, outline := range outlines {
+		index := fmt.Sprintf("%s%d", prefix, i)
+
+		if outline.IsFolder() {
+			if err := validateOutlines(outline.Outlines, index+"."); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Unreachable outlines intentionally have no feed URL
+		if outline.IsUnreachable() {
+			continue
+		}
+
 		if outline.XMLURL == "" {
-			return fmt.Errorf("outline %d is missing xmlUrl attribute", i)
+			return fmt.Errorf("outline %s is missing xmlUrl attribute", index)
 		}
 
+		// htmlUrl is optional in OPML 1.0 and 2.0, and existing files being appended to may omit it
 		if outline.HTMLURL == "" {
-			return fmt.Errorf("outline %d is missing htmlUrl attribute", i)
+			logrus.WithField("outline_index", index).Debug("Outline is missing htmlUrl attribute")
 		}
 
 		if outline.Title == "" && outline.Text == "" {
-			logrus.WithField("outline_index", i).Warn("Outline has no title or text")
+			logrus.WithField("outline_index", index).Warn("Outline has no title or text")
 		}
 	}
 
-	logrus.WithField("outline_count", len(opml.Body.Outlines)).Debug("OPML validation passed")
+	return nil
+}
+
+// ClearTimestamps removes the dateCreated and dateModified head elements, so
+// that exporting the same feeds twice produces identical documents
+func (o *OPML) ClearTimestamps() {
+	o.Head.DateCreated = ""
+	o.Head.DateModified = ""
+}
+
+// Sort modes accepted by SortOutlines
+const (
+	SortNone  = "none"
+	SortTitle = "title"
+	SortURL   = "url"
+)
+
+// SortOutlines orders the document's outlines by feed title (case-insensitive)
+// or by feed URL. Outlines inside folders are sorted within their folder, and
+// equal keys keep their original order. Mode "none" (or empty) leaves the order unchanged.
+func (o *OPML) SortOutlines(mode string) error {
+	var key func(Outline) string
+	switch mode {
+	case "", SortNone:
+		return nil
+	case SortTitle:
+		key = func(outline Outline) string {
+			if outline.Title != "" {
+				return strings.ToLower(outline.Title)
+			}
+			return strings.ToLower(outline.Text)
+		}
+	case SortURL:
+		key = func(outline Outline) string {
+			return strings.ToLower(outline.XMLURL)
+		}
+	default:
+		return fmt.Errorf("unknown sort mode %q (use title, url or none)", mode)
+	}
 
+	sortOutlines(o.Body.Outlines, key)
+	logrus.WithField("mode", mode).Debug("Sorted OPML outlines")
 	return nil
 }
 
+// sortOutlines stably sorts outlines by key, recursing into folders
+func sortOutlines(outlines []Outline, key func(Outline) string) {
+	sort.SliceStable(outlines, func(i, j int) bool {
+		return key(outlines[i]) < key(outlines[j])
+	})
+
+	for i := range outlines {
+		if outlines[i].IsFolder() {
+			sortOutlines(outlines[i].Outlines, key)
+		}
+	}
+}
+
+// Sources for the text attribute accepted by SetOutlineText
+const (
+	TextSourceTitle  = "title"
+	TextSourceDomain = "domain"
+	TextSourceURL    = "url"
+)
+
+// SetOutlineText sets the text attribute of each feed outline independently
+// of its title: to the feed title, to the site's host name (without "www."),
+// or to the site URL. Sites without an htmlUrl fall back to the feed URL.
+// Folders and unreachable outlines are left alone.
+func (o *OPML) SetOutlineText(source string) error {
+	text, err := outlineText(source)
+	if err != nil {
+		return err
+	}
+
+	setOutlineText(o.Body.Outlines, text)
+	logrus.WithField("source", source).Debug("Set OPML outline text")
+	return nil
+}
+
+// outlineText returns the function computing an outline's text for a text source
+func outlineText(source string) (func(Outline) string, error) {
+	switch source {
+	case "", TextSourceTitle:
+		return func(outline Outline) string { return outline.Title }, nil
+	case TextSourceDomain:
+		return func(outline Outline) string {
+			parsed, err := url.Parse(siteURL(outline))
+			if err != nil || parsed.Hostname() == "" {
+				return outline.Title
+			}
+			return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+		}, nil
+	case TextSourceURL:
+		return siteURL, nil
+	default:
+		return nil, fmt.Errorf("unknown text source %q (use title, domain or url)", source)
+	}
+}
+
+// setOutlineText sets the text of each feed outline, recursing into folders
+func setOutlineText(outlines []Outline, text func(Outline) string) {
+	for i := range outlines {
+		outline := &outlines[i]
+		if outline.IsFolder() {
+			setOutlineText(outline.Outlines, text)
+			continue
+		}
+		if outline.XMLURL == "" || outline.IsUnreachable() {
+			continue
+		}
+		if value := text(*outline); value != "" {
+			outline.Text = value
+		}
+	}
+}
+
+// siteURL returns the outline's site URL, or its feed URL if it has none
+func siteURL(outline Outline) string {
+	if outline.HTMLURL != "" {
+		return outline.HTMLURL
+	}
+	return outline.XMLURL
+}
+
+// FeedCount returns the number of feed outlines (those with an xmlUrl) in the
+// document, including feeds nested in folders
+func (o *OPML) FeedCount() int {
+	return countFeeds(o.Body.Outlines)
+}
+
+// FeedURLs returns the xmlUrl of every feed outline in the document,
+// including feeds nested in folders
+func (o *OPML) FeedURLs() []string {
+	return collectFeedURLs(o.Body.Outlines, nil)
+}
+
+// collectFeedURLs recursively appends the xmlUrls of outlines that point at a feed
+func collectFeedURLs(outlines []Outline, urls []string) []string {
+	for _, outline := range outlines {
+		if outline.XMLURL != "" && !outline.IsUnreachable() {
+			urls = append(urls, outline.XMLURL)
+		}
+		urls = collectFeedURLs(outline.Outlines, urls)
+	}
+	return urls
+}
+
+// countFeeds recursively counts outlines that point at a feed
+func countFeeds(outlines []Outline) int {
+	count := 0
+	for _, outline := range outlines {
+		if outline.XMLURL != "" && !outline.IsUnreachable() {
+			count++
+		}
+		count += countFeeds(outline.Outlines)
+	}
+	return count
+}
+
 // GetStats returns statistics about the OPML document
 func (o *OPML) GetStats() map[string]interface{} {
 	return map[string]interface{}{
 		"version":       o.Version,
 		"title":         o.Head.Title,
 		"outline_count": len(o.Body.Outlines),
+		"feed_count":    o.FeedCount(),
 		"date_created":  o.Head.DateCreated,
 	}
 }
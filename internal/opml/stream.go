@@ -0,0 +1,221 @@
+package opml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lmorchard/linkding-to-opml/internal/atomicfile"
+	"github.com/lmorchard/linkding-to-opml/internal/feeds"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StreamOptions controls the outlines written by a StreamWriter
+type StreamOptions struct {
+	IncludeIcons bool   // Add the non-standard iconUrl attribute
+	TextSource   string // Source of the text attribute, as for SetOutlineText
+}
+
+// StreamWriter writes an OPML 2.0 document one feed outline at a time, so a
+// large export never holds its whole outline list in memory. The head is
+// written with the first outline; Commit writes the closing tags.
+type StreamWriter struct {
+	w       io.Writer
+	encoder *xml.Encoder
+	title   string
+	options StreamOptions
+	text    func(Outline) string
+
+	// Set when streaming to a file: the output goes to tempFile, which
+	// Commit renames over filePath
+	file     *os.File
+	tempFile string
+	filePath string
+
+	started bool
+	count   int
+}
+
+// NewStreamWriter creates a writer that streams an OPML document to w
+func NewStreamWriter(w io.Writer, title string, options StreamOptions) (*StreamWriter, error) {
+	text, err := outlineText(options.TextSource)
+	if err != nil {
+		return nil, err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	return &StreamWriter{
+		w:       w,
+		encoder: encoder,
+		title:   title,
+		options: options,
+		text:    text,
+	}, nil
+}
+
+// CreateStreamFile creates a writer that streams an OPML document to a
+// temporary file next to filePath, which Commit renames over filePath, or to
+// stdout when filePath is "-"
+func CreateStreamFile(filePath, title string, options StreamOptions) (*StreamWriter, error) {
+	if filePath == StdioPath {
+		return NewStreamWriter(os.Stdout, title, options)
+	}
+
+	if err := createParentDir(filePath); err != nil {
+		return nil, err
+	}
+
+	file, err := atomicfile.CreateTemp(filePath, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary OPML file: %w", err)
+	}
+	tempFile := file.Name()
+
+	s, err := NewStreamWriter(file, title, options)
+	if err != nil {
+		file.Close()
+		os.Remove(tempFile)
+		return nil, err
+	}
+	s.file = file
+	s.tempFile = tempFile
+	s.filePath = filePath
+	return s, nil
+}
+
+// WriteResult writes the outline for a successful discovery result. Failed
+// results are ignored. It is not safe for concurrent use.
+func (s *StreamWriter) WriteResult(result *feeds.FeedDiscoveryResult) error {
+	if !result.IsSuccessful() {
+		return nil
+	}
+
+	if !s.started {
+		if err := s.writeStart(); err != nil {
+			return err
+		}
+		s.started = true
+	}
+
+	outline := feedOutline(result)
+	if s.options.IncludeIcons {
+		outline.IconURL = result.IconURL
+	}
+	if text := s.text(outline); text != "" {
+		outline.Text = text
+	}
+
+	if err := s.encoder.Encode(outline); err != nil {
+		return fmt.Errorf("failed to encode OPML outline: %w", err)
+	}
+	s.count++
+	return nil
+}
+
+// writeStart writes the XML declaration, the head and the opening body tag
+func (s *StreamWriter) writeStart() error {
+	if _, err := io.WriteString(s.w, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"); err != nil {
+		return fmt.Errorf("failed to write XML declaration: %w", err)
+	}
+
+	opmlStart := xml.StartElement{
+		Name: xml.Name{Local: "opml"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "version"}, Value: DefaultVersion}},
+	}
+	if err := s.encoder.EncodeToken(opmlStart); err != nil {
+		return fmt.Errorf("failed to encode OPML: %w", err)
+	}
+	if err := s.encoder.Encode(newHead(s.title)); err != nil {
+		return fmt.Errorf("failed to encode OPML head: %w", err)
+	}
+	if err := s.encoder.EncodeToken(xml.StartElement{Name: xml.Name{Local: "body"}}); err != nil {
+		return fmt.Errorf("failed to encode OPML: %w", err)
+	}
+	return nil
+}
+
+// Count returns the number of outlines written so far
+func (s *StreamWriter) Count() int {
+	return s.count
+}
+
+// Commit writes the closing tags and, when streaming to a file, moves it into
+// place, first backing up an existing file when backup is set. It returns
+// the backup path, or an empty string if nothing was backed up.
+func (s *StreamWriter) Commit(backup bool) (string, error) {
+	// A document without outlines still gets its head
+	if !s.started {
+		if err := s.writeStart(); err != nil {
+			s.Abort()
+			return "", err
+		}
+		s.started = true
+	}
+	if err := s.finish(); err != nil {
+		s.Abort()
+		return "", err
+	}
+	if s.file == nil {
+		logrus.WithField("outline_count", s.count).Info("Successfully streamed OPML to stdout")
+		return "", nil
+	}
+
+	if err := s.file.Close(); err != nil {
+		os.Remove(s.tempFile)
+		return "", fmt.Errorf("failed to write OPML file: %w", err)
+	}
+	s.file = nil
+
+	backedUp, err := replaceFile(s.tempFile, s.filePath, backup)
+	if err != nil {
+		return backedUp, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"file_path":     s.filePath,
+		"outline_count": s.count,
+	}).Info("Successfully streamed OPML file")
+	return backedUp, nil
+}
+
+// Abort discards a document streamed to a file, leaving any existing file
+// untouched. A document already streamed to stdout is closed off instead.
+func (s *StreamWriter) Abort() {
+	if s.file == nil {
+		if s.tempFile == "" {
+			_ = s.finish()
+		}
+		return
+	}
+
+	s.file.Close()
+	s.file = nil
+	os.Remove(s.tempFile)
+}
+
+// finish writes the closing body and opml tags of a started document
+func (s *StreamWriter) finish() error {
+	if !s.started {
+		return nil
+	}
+	s.started = false
+
+	for _, name := range []string{"body", "opml"} {
+		if err := s.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}}); err != nil {
+			return fmt.Errorf("failed to encode OPML: %w", err)
+		}
+	}
+	if err := s.encoder.Flush(); err != nil {
+		return fmt.Errorf("failed to flush XML encoder: %w", err)
+	}
+
+	// Terminate the document so shell pipelines get a complete final line
+	if _, err := io.WriteString(s.w, "\n"); err != nil {
+		return fmt.Errorf("failed to write newline: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package opml
+
+import (
+	"fmt"
+
+	"github.com/lmorchard/linkding-to-opml/internal/feeds"
+)
+
+// OPML versions that can be generated
+const (
+	Version1       = "1.0"
+	Version2       = "2.0"
+	DefaultVersion = Version2
+)
+
+// versionProfile holds the details that differ between OPML versions
+type versionProfile struct {
+	// docs is the head <docs> URL; OPML 1.0 has no such element
+	docs string
+	// feedType maps a discovered feed format to the outline type attribute
+	feedType func(feedType string) string
+	// extendedAttrs is true if language, category, iconUrl and hubUrl outline attributes are kept
+	extendedAttrs bool
+}
+
+var versionProfiles = map[string]versionProfile{
+	Version1: {
+		// 1.0-era readers only recognize type="rss", whatever the feed format
+		feedType:      func(string) string { return feeds.FeedTypeRSS },
+		extendedAttrs: false,
+	},
+	Version2: {
+		docs:          "http://www.opml.org/spec2",
+		feedType:      func(feedType string) string { return feedType },
+		extendedAttrs: true,
+	},
+}
+
+// IsSupportedVersion returns true if documents of the given OPML version can
+// be generated and validated
+func IsSupportedVersion(version string) bool {
+	_, ok := versionProfiles[version]
+	return ok
+}
+
+// SetVersion converts the document to the given OPML version, adjusting the
+// head and the feed outlines to that version's conventions
+func (o *OPML) SetVersion(version string) error {
+	profile, ok := versionProfiles[version]
+	if !ok {
+		return fmt.Errorf("unsupported OPML version %q (use %s or %s)", version, Version1, Version2)
+	}
+
+	o.Version = version
+	// Keep a docs URL an existing document already carries, unless the version has none
+	if profile.docs == "" || o.Head.Docs == "" {
+		o.Head.Docs = profile.docs
+	}
+	applyVersionProfile(o.Body.Outlines, profile)
+
+	return nil
+}
+
+// applyVersionProfile rewrites feed outlines for a version, recursing into folders
+func applyVersionProfile(outlines []Outline, profile versionProfile) {
+	for i := range outlines {
+		outline := &outlines[i]
+		if outline.IsFolder() {
+			applyVersionProfile(outline.Outlines, profile)
+			continue
+		}
+		if outline.IsUnreachable() || outline.XMLURL == "" {
+			continue
+		}
+
+		outline.Type = profile.feedType(outline.Type)
+		if !profile.extendedAttrs {
+			outline.Language = ""
+			outline.Category = ""
+			outline.IconURL = ""
+			outline.HubURL = ""
+		}
+	}
+}
@@ -25,7 +25,7 @@ type ProcessingStats struct {
 
 	// Progress tracking
 	mu               sync.RWMutex
-	progressCallback func(processed, total int64, url string, success bool)
+	progressCallback func(processed, total int64, url string, success bool, eta time.Duration)
 }
 
 // StatTracker manages statistics collection during processing
@@ -43,8 +43,9 @@ func NewStatTracker(totalBookmarks int) *StatTracker {
 	}
 }
 
-// SetProgressCallback sets a callback function for progress reporting
-func (st *StatTracker) SetProgressCallback(callback func(processed, total int64, url string, success bool)) {
+// SetProgressCallback sets a callback function for progress reporting. The
+// callback receives the estimated time remaining, or zero until one is known.
+func (st *StatTracker) SetProgressCallback(callback func(processed, total int64, url string, success bool, eta time.Duration)) {
 	st.stats.mu.Lock()
 	defer st.stats.mu.Unlock()
 	st.stats.progressCallback = callback
@@ -76,8 +77,8 @@ func (st *StatTracker) reportProgress(url string, success bool) {
 	st.stats.mu.RUnlock()
 
 	if callback != nil {
-		processed := atomic.LoadInt64(&st.stats.CacheHits) + atomic.LoadInt64(&st.stats.NewDiscoveries)
-		callback(processed, st.stats.TotalBookmarks, url, success)
+		processed := st.GetProcessedCount()
+		callback(processed, st.stats.TotalBookmarks, url, success, st.ETA())
 	}
 }
 
@@ -118,23 +119,78 @@ func (st *StatTracker) FormatSummary(quiet bool) string {
 
 	stats := st.GetStats()
 
-	return fmt.Sprintf("Found %d feeds from %d bookmarks, %d cached, %d newly discovered, %d failed (Processing time: %v)",
+	return fmt.Sprintf("Found %d feeds from %d bookmarks, %d cached, %d newly discovered, %d failed (Processing time: %s)",
 		stats.SuccessfulFeeds,
 		stats.TotalBookmarks,
 		stats.CacheHits,
 		stats.NewDiscoveries,
 		stats.FailedDiscoveries,
-		stats.ProcessingTime.Round(time.Second))
+		FormatDuration(stats.ProcessingTime))
 }
 
-// FormatProgressUpdate creates a progress update message
-func FormatProgressUpdate(processed, total int64, url string, success bool) string {
+// TimestampLayout is the layout used for absolute times in user-facing summaries
+const TimestampLayout = "2006-01-02 15:04:05"
+
+// FormatDuration renders a duration for humans, e.g. "3m42s" or "1h5s",
+// omitting zero components and showing milliseconds for sub-second runs
+func FormatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+
+	d = d.Round(time.Second)
+	hours := int64(d / time.Hour)
+	minutes := int64((d % time.Hour) / time.Minute)
+	seconds := int64((d % time.Minute) / time.Second)
+
+	var formatted string
+	if hours > 0 {
+		formatted += fmt.Sprintf("%dh", hours)
+	}
+	if minutes > 0 {
+		formatted += fmt.Sprintf("%dm", minutes)
+	}
+	if seconds > 0 {
+		formatted += fmt.Sprintf("%ds", seconds)
+	}
+
+	return formatted
+}
+
+// FormatTimeRange renders the absolute start and end times of a run
+func FormatTimeRange(start, end time.Time) string {
+	return fmt.Sprintf("Started: %s, Finished: %s", start.Format(TimestampLayout), end.Format(TimestampLayout))
+}
+
+// EstimateRemaining extrapolates the time left from the average time per item
+// so far. It returns zero before anything has been processed or once all
+// items are done.
+func EstimateRemaining(elapsed time.Duration, processed, total int64) time.Duration {
+	if processed <= 0 || processed >= total {
+		return 0
+	}
+	perItem := elapsed / time.Duration(processed)
+	return perItem * time.Duration(total-processed)
+}
+
+// FormatETA renders an estimated time remaining for progress output, e.g.
+// "ETA 3m12s", or "ETA unknown" before there is an estimate
+func FormatETA(eta time.Duration) string {
+	if eta <= 0 {
+		return "ETA unknown"
+	}
+	return "ETA " + FormatDuration(eta)
+}
+
+// FormatProgressUpdate creates a progress update message, e.g.
+// "[120/4200] ETA 3m12s ✓ https://example.com"
+func FormatProgressUpdate(processed, total int64, url string, success bool, eta time.Duration) string {
 	status := "✓"
 	if !success {
 		status = "✗"
 	}
 
-	return fmt.Sprintf("[%d/%d] %s %s", processed, total, status, url)
+	return fmt.Sprintf("[%d/%d] %s %s %s", processed, total, FormatETA(eta), status, url)
 }
 
 // LogVerboseProgress logs detailed progress information
@@ -161,6 +217,12 @@ func (st *StatTracker) GetProcessedCount() int64 {
 	return atomic.LoadInt64(&st.stats.CacheHits) + atomic.LoadInt64(&st.stats.NewDiscoveries)
 }
 
+// ETA returns the estimated time until all bookmarks are processed, based on
+// the average processing time per bookmark so far
+func (st *StatTracker) ETA() time.Duration {
+	return EstimateRemaining(time.Since(st.stats.StartTime), st.GetProcessedCount(), st.stats.TotalBookmarks)
+}
+
 // GetSuccessRate returns the success rate as a percentage
 func (st *StatTracker) GetSuccessRate() float64 {
 	processed := st.GetProcessedCount()
@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"os"
 
-	"linkding-to-opml/cmd"
+	"github.com/lmorchard/linkding-to-opml/cmd"
 )
 
 func main() {
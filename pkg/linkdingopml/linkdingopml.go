@@ -0,0 +1,139 @@
+// Package linkdingopml exposes linkding-to-opml's bookmark fetching, feed
+// discovery and OPML generation for use from other Go programs.
+//
+// The supported API is what this package declares: the functions below and
+// the type aliases Bookmark, Client, FeedDiscoveryResult, DiscoveryOptions,
+// HTTPClient, HTTPConfig, ProcessingConfig, ProcessingStats, Cache, OPML and
+// Outline. Their exported fields and methods are usable, but the internal
+// packages they come from may change without notice; only the signatures
+// declared here are kept stable.
+//
+// A typical export fetches bookmarks, discovers their feeds and writes OPML:
+//
+//	bookmarks, err := linkdingopml.FetchBookmarks("https://linkding.example.com", token, nil)
+//	...
+//	resultCache, err := linkdingopml.NewCache("file", "feeds.gob", "")
+//	...
+//	processingConfig, err := linkdingopml.DefaultProcessingConfig()
+//	...
+//	found, _, _ := linkdingopml.ProcessBookmarks(ctx, bookmarks, resultCache, processingConfig)
+//	err = linkdingopml.WriteOPML(linkdingopml.GenerateOPML(found, "My feeds"), "feeds.opml")
+package linkdingopml
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lmorchard/linkding-to-opml/internal/cache"
+	"github.com/lmorchard/linkding-to-opml/internal/config"
+	"github.com/lmorchard/linkding-to-opml/internal/feeds"
+	"github.com/lmorchard/linkding-to-opml/internal/linkding"
+	"github.com/lmorchard/linkding-to-opml/internal/opml"
+)
+
+type (
+	// Bookmark is a Linkding bookmark
+	Bookmark = linkding.Bookmark
+	// Client talks to the Linkding API
+	Client = linkding.Client
+
+	// FeedDiscoveryResult is the outcome of discovering the feed of one page
+	FeedDiscoveryResult = feeds.FeedDiscoveryResult
+	// DiscoveryOptions controls a single feed discovery
+	DiscoveryOptions = feeds.DiscoveryOptions
+	// HTTPClient fetches pages and feeds
+	HTTPClient = feeds.HTTPClient
+	// HTTPConfig configures an HTTPClient
+	HTTPConfig = feeds.HTTPConfig
+	// ProcessingConfig controls how ProcessBookmarks discovers feeds
+	ProcessingConfig = feeds.ProcessingConfig
+	// ProcessingStats summarizes a ProcessBookmarks run
+	ProcessingStats = feeds.ProcessingStats
+
+	// Cache stores discovery results between runs
+	Cache = cache.Cache
+
+	// OPML is an OPML document
+	OPML = opml.OPML
+	// Outline is a single OPML outline
+	Outline = opml.Outline
+)
+
+// DefaultLinkdingTimeout is the Linkding API timeout used by FetchBookmarks
+const DefaultLinkdingTimeout = 30 * time.Second
+
+// NewClient creates a Linkding API client. The URL is the server's base URL;
+// a missing scheme defaults to https.
+func NewClient(token, url string, timeout time.Duration) (*Client, error) {
+	return linkding.NewClient(token, url, timeout)
+}
+
+// FetchBookmarks returns the bookmarks on a Linkding server that carry all
+// of the given tags, or every bookmark when tags is empty. Tags containing *
+// are glob patterns.
+func FetchBookmarks(url, token string, tags []string) ([]*Bookmark, error) {
+	client, err := linkding.NewClient(token, url, DefaultLinkdingTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Linkding client: %w", err)
+	}
+	return client.FetchBookmarks(tags)
+}
+
+// NewHTTPClient creates the HTTP client used for feed discovery
+func NewHTTPClient(config HTTPConfig) *HTTPClient {
+	return feeds.NewHTTPClient(config)
+}
+
+// DiscoverFeed finds and validates the feed of a single page. The discovery
+// is abandoned if ctx is cancelled. opts.HTTPClient is required.
+func DiscoverFeed(ctx context.Context, pageURL string, opts DiscoveryOptions) *FeedDiscoveryResult {
+	return feeds.DiscoverFeedWithOptions(ctx, pageURL, opts)
+}
+
+// NewCache creates a result cache: backend "file" (the default) saved whole
+// as gob or JSON, or "sqlite" written incrementally. Call LoadCache before
+// use, and SaveCache and Close when done.
+func NewCache(backend, filePath, format string) (Cache, error) {
+	return cache.New(backend, filePath, format, false)
+}
+
+// DefaultProcessingConfig returns the settings the export command uses when
+// nothing is configured
+func DefaultProcessingConfig() (ProcessingConfig, error) {
+	cfg, err := config.DefaultConfig()
+	if err != nil {
+		return ProcessingConfig{}, err
+	}
+
+	tlsConfig, err := cfg.TLSConfig()
+	if err != nil {
+		return ProcessingConfig{}, err
+	}
+	return cfg.ProcessingConfig(tlsConfig), nil
+}
+
+// ProcessBookmarks discovers the feeds of bookmarks concurrently, using and
+// updating resultCache. It returns the successful results, deduplicated by
+// feed URL, and the failed ones. Cancelling ctx stops discovery early and
+// returns the partial results with stats.Interrupted set.
+func ProcessBookmarks(ctx context.Context, bookmarks []*Bookmark, resultCache Cache, processingConfig ProcessingConfig) ([]*FeedDiscoveryResult, []*FeedDiscoveryResult, *ProcessingStats) {
+	return feeds.ProcessBookmarks(ctx, bookmarks, resultCache, processingConfig)
+}
+
+// GenerateOPML creates an OPML document with an outline for each successful result
+func GenerateOPML(results []*FeedDiscoveryResult, title string) *OPML {
+	return opml.GenerateOPML(results, title)
+}
+
+// WriteOPML writes an OPML document to a file, replacing it atomically, or to
+// stdout when filePath is "-"
+func WriteOPML(doc *OPML, filePath string) error {
+	return opml.WriteOPML(doc, filePath)
+}
+
+// EncodeOPML writes an OPML document, including the XML declaration, to w
+func EncodeOPML(doc *OPML, w io.Writer) error {
+	return opml.EncodeOPML(doc, w)
+}